@@ -0,0 +1,127 @@
+package gopex
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+// maxActions is the number of actions that fit in an actionMask
+const maxActions = 64
+
+// actionRegistry holds the registered actions, indexed by their value
+var actionRegistry = struct {
+	sync.RWMutex
+	letters []byte
+	names   []string
+	// count is the number of registered actions, read atomically to check actions without locking
+	count uint32
+}{
+	letters: []byte{PermissionRead[0], PermissionWrite[0]},
+	names:   []string{"read", "write"},
+	count:   2,
+}
+
+// RegisterAction registers a new action, returning its value. The action is granted in permission tags
+// by its letter, like "user:rwd", or by its name, like "user:read|delete". Letters and names must be
+// unique, can not contain whitespace nor the ',', ':', '|' and '*' characters, and names must have at
+// least two characters and not be made only of registered letters, like "rw", so that they are not mistaken
+// by letters and do not change the meaning of existing tags. At most 64 actions can be registered,
+// ActionRead and ActionWrite included.
+//
+// It returns an *Error wrapping ErrInvalidAction if the action can not be registered.
+func RegisterAction(letter byte, name string) (Action, error) {
+	fail := func(format string, args ...interface{}) (Action, error) {
+		return 0, &Error{Err: fmt.Errorf("%w %q: %s", ErrInvalidAction, name, fmt.Sprintf(format, args...))}
+	}
+
+	if !isActionName(string(letter)) {
+		return fail("invalid letter %q", letter)
+	}
+	if len(name) < 2 || !isActionName(name) {
+		return fail("invalid name")
+	}
+
+	actionRegistry.Lock()
+	defer actionRegistry.Unlock()
+
+	if len(actionRegistry.names) == maxActions {
+		return fail("more than %d actions", maxActions)
+	}
+	for i := range actionRegistry.names {
+		if actionRegistry.letters[i] == letter {
+			return fail("letter %q already used by %q", letter, actionRegistry.names[i])
+		}
+		if actionRegistry.names[i] == name {
+			return fail("name already registered")
+		}
+	}
+
+	if isMadeOfLetters(name, append(append([]byte{}, actionRegistry.letters...), letter)) {
+		return fail("name made only of action letters")
+	}
+
+	actionRegistry.letters = append(actionRegistry.letters, letter)
+	actionRegistry.names = append(actionRegistry.names, name)
+	atomic.StoreUint32(&actionRegistry.count, uint32(len(actionRegistry.names)))
+	return Action(len(actionRegistry.names) - 1), nil
+}
+
+// LookupAction returns the action with a letter or a name, or false if there is none
+func LookupAction(letterOrName string) (Action, bool) {
+	actionRegistry.RLock()
+	defer actionRegistry.RUnlock()
+
+	for i, name := range actionRegistry.names {
+		if name == letterOrName || len(letterOrName) == 1 && actionRegistry.letters[i] == letterOrName[0] {
+			return Action(i), true
+		}
+	}
+
+	return 0, false
+}
+
+// ActionName returns the name of an action, or an empty string if it is not registered
+func ActionName(action Action) string {
+	actionRegistry.RLock()
+	defer actionRegistry.RUnlock()
+
+	if action >= uint(len(actionRegistry.names)) {
+		return ""
+	}
+
+	return actionRegistry.names[action]
+}
+
+// isRegisteredName returns true if there is an action with the name
+func isRegisteredName(name string) bool {
+	action, ok := LookupAction(name)
+	return ok && ActionName(action) == name
+}
+
+// checkAction returns an error wrapping ErrUnknownAction if the action is not registered
+func checkAction(action Action) error {
+	if action >= uint(atomic.LoadUint32(&actionRegistry.count)) {
+		return fmt.Errorf("%w %d", ErrUnknownAction, action)
+	}
+
+	return nil
+}
+
+// isMadeOfLetters returns true if every character of a name is one of the letters
+func isMadeOfLetters(name string, letters []byte) bool {
+	for i := 0; i < len(name); i++ {
+		if bytes.IndexByte(letters, name[i]) < 0 {
+			return false
+		}
+	}
+
+	return true
+}
+
+// isActionName returns true if a string can be the letter or the name of an action
+func isActionName(name string) bool {
+	return name != "" && !strings.ContainsAny(name, ",:|* \t\r\n")
+}
@@ -0,0 +1,129 @@
+package gopex
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+// Actions registered for the tests
+var (
+	actionDelete, _ = RegisterAction('d', "delete")
+	actionExport, _ = RegisterAction('e', "export")
+)
+
+// Struct with registered actions
+type AAStruct struct {
+	Name   string `pex:"user:rd,admin:rwde"`
+	Salary uint   `pex:"user:read,admin:read|export"`
+}
+
+func TestActions(t *testing.T) {
+	t.Run("TestRegisterAction", testRegisterAction)
+	t.Run("TestLookupAction", testLookupAction)
+	t.Run("TestExtractRegisteredActions", testExtractRegisteredActions)
+	t.Run("TestUnknownAction", testUnknownAction)
+}
+
+func testRegisterAction(t *testing.T) {
+	t.Parallel()
+
+	tables := []struct {
+		letter byte
+		name   string
+	}{
+		{'r', "retrieve"},
+		{'q', "read"},
+		{'d', "destroy"},
+		{',', "comma"},
+		{'|', "pipe"},
+		{'q', "q"},
+		{'q', "query|search"},
+		{'q', "query all"},
+		{'q', "rw"},
+		{'q', "wr"},
+		{'q', "qd"},
+	}
+
+	for _, table := range tables {
+		if action, err := RegisterAction(table.letter, table.name); !errors.Is(err, ErrInvalidAction) {
+			t.Errorf("%s (letter = %q, name = %s) was incorrect, got: %d, %v, want: %v.", t.Name(), table.letter,
+				table.name, action, err, ErrInvalidAction)
+		}
+	}
+}
+
+func testLookupAction(t *testing.T) {
+	t.Parallel()
+
+	tables := []struct {
+		letterOrName string
+		expected     Action
+		ok           bool
+	}{
+		{"r", ActionRead, true},
+		{"read", ActionRead, true},
+		{"w", ActionWrite, true},
+		{"write", ActionWrite, true},
+		{"d", actionDelete, true},
+		{"export", actionExport, true},
+		{"list", 0, false},
+		{"", 0, false},
+	}
+
+	for _, table := range tables {
+		action, ok := LookupAction(table.letterOrName)
+		if action != table.expected || ok != table.ok {
+			t.Errorf("%s (letterOrName = %s) was incorrect, got: %d, %t, want: %d, %t.", t.Name(),
+				table.letterOrName, action, ok, table.expected, table.ok)
+		}
+	}
+
+	if name := ActionName(actionDelete); name != "delete" {
+		t.Errorf("%s (action = %d) was incorrect, got: %s, want: delete.", t.Name(), actionDelete, name)
+	}
+	if name := ActionName(maxActions); name != "" {
+		t.Errorf("%s (action = %d) was incorrect, got: %s, want: .", t.Name(), maxActions, name)
+	}
+}
+
+func testExtractRegisteredActions(t *testing.T) {
+	t.Parallel()
+
+	object := AAStruct{Name: "ABC", Salary: 10}
+	tables := []struct {
+		userType string
+		action   uint
+		expected interface{}
+	}{
+		{"user", ActionRead, map[string]interface{}{"Name": "ABC", "Salary": uint(10)}},
+		{"user", actionDelete, map[string]interface{}{"Name": "ABC"}},
+		{"user", actionExport, map[string]interface{}{}},
+		{"admin", actionDelete, map[string]interface{}{"Name": "ABC"}},
+		{"admin", actionExport, map[string]interface{}{"Name": "ABC", "Salary": uint(10)}},
+	}
+
+	for _, table := range tables {
+		result, err := ExtractFieldsE(object, table.userType, table.action)
+		if err != nil || !reflect.DeepEqual(result, table.expected) {
+			t.Errorf("%s (object = %+v, userType = %s, action = %d) was incorrect, got: %+v, %v, want: %+v.",
+				t.Name(), object, table.userType, table.action, result, err, table.expected)
+		}
+	}
+}
+
+func testUnknownAction(t *testing.T) {
+	t.Parallel()
+
+	if _, err := checkPermission("", "user", maxActions); !errors.Is(err, ErrUnknownAction) {
+		t.Errorf("%s was incorrect, got: %v, want: %v.", t.Name(), err, ErrUnknownAction)
+	}
+	if hasPermission("user:r", "user", maxActions) {
+		t.Errorf("%s was incorrect, got: true, want: false.", t.Name())
+	}
+
+	result, err := ExtractFieldsE(AAStruct{Name: "ABC"}, "user", maxActions)
+	if result != nil || !errors.Is(err, ErrUnknownAction) {
+		t.Errorf("%s was incorrect, got: %+v, %v, want: %v.", t.Name(), result, err, ErrUnknownAction)
+	}
+}
@@ -0,0 +1,146 @@
+package gopex
+
+import (
+	"fmt"
+	"reflect"
+)
+
+// visit identifies a pointer, map or slice already walked, to keep aliasing and stop cycles
+type visit struct {
+	pointer     uintptr
+	length      int
+	reflectType reflect.Type
+}
+
+// cleaner deep copies values setting the fields a user has not access to their zero values
+type cleaner struct {
+	extractor *Extractor
+	principal Principal
+	action    uint
+	// copies are the pointers already copied and their copies
+	copies map[visit]reflect.Value
+}
+
+// copyValue returns a deep copy of a value with the fields the user has not access set to their
+// zero values
+func (c *cleaner) copyValue(value reflect.Value, path string) (reflect.Value, error) {
+	if value.Kind() != reflect.Ptr && value.Kind() != reflect.Interface && c.extractor.isLeaf(value.Type()) {
+		return value, nil
+	}
+
+	switch value.Kind() {
+	case reflect.Ptr:
+		if value.IsNil() {
+			return value, nil
+		}
+
+		key := visit{pointer: value.Pointer(), reflectType: value.Type()}
+		if copied, ok := c.copies[key]; ok {
+			return copied, nil
+		}
+
+		copied := reflect.New(value.Type().Elem())
+		c.copies[key] = copied
+		elem, err := c.copyValue(value.Elem(), path)
+		if err != nil {
+			return reflect.Value{}, err
+		}
+		copied.Elem().Set(elem)
+		return copied, nil
+	case reflect.Interface:
+		if value.IsNil() {
+			return value, nil
+		}
+
+		elem, err := c.copyValue(value.Elem(), path)
+		if err != nil {
+			return reflect.Value{}, err
+		}
+		copied := reflect.New(value.Type()).Elem()
+		copied.Set(elem)
+		return copied, nil
+	case reflect.Struct:
+		return c.copyStruct(value, path)
+	case reflect.Slice:
+		if value.IsNil() {
+			return value, nil
+		}
+
+		key := visit{pointer: value.Pointer(), length: value.Len(), reflectType: value.Type()}
+		if copied, ok := c.copies[key]; ok {
+			return copied, nil
+		}
+
+		copied := reflect.MakeSlice(value.Type(), value.Len(), value.Len())
+		c.copies[key] = copied
+		return copied, c.copyElements(copied, value, path)
+	case reflect.Array:
+		copied := reflect.New(value.Type()).Elem()
+		return copied, c.copyElements(copied, value, path)
+	case reflect.Map:
+		if value.IsNil() {
+			return value, nil
+		}
+
+		visited := visit{pointer: value.Pointer(), reflectType: value.Type()}
+		if copied, ok := c.copies[visited]; ok {
+			return copied, nil
+		}
+
+		copied := reflect.MakeMapWithSize(value.Type(), value.Len())
+		c.copies[visited] = copied
+		for _, key := range value.MapKeys() {
+			keyPath := path + "[" + fmt.Sprint(key.Interface()) + "]"
+			elem, err := c.copyValue(value.MapIndex(key), keyPath)
+			if err != nil {
+				return reflect.Value{}, err
+			}
+			copied.SetMapIndex(key, elem)
+		}
+		return copied, nil
+	default:
+		return value, nil
+	}
+}
+
+// copyStruct returns a copy of a struct with the fields the user has not access set to their zero
+// values. Special objects and unexported fields are copied as they are.
+func (c *cleaner) copyStruct(value reflect.Value, path string) (reflect.Value, error) {
+	copied := reflect.New(value.Type()).Elem()
+	copied.Set(value)
+
+	for _, field := range c.extractor.plans.get(value.Type()).fields {
+		fieldPath := fieldPath(path, field.path)
+		allowed, err := field.allows(c.extractor, c.principal, c.action)
+		if err != nil {
+			return reflect.Value{}, &Error{Path: fieldPath, Err: err}
+		}
+
+		fieldValue := copied.FieldByIndex(field.index)
+		if !allowed {
+			fieldValue.Set(reflect.Zero(fieldValue.Type()))
+			continue
+		}
+
+		elem, err := c.copyValue(value.FieldByIndex(field.index), fieldPath)
+		if err != nil {
+			return reflect.Value{}, err
+		}
+		fieldValue.Set(elem)
+	}
+
+	return copied, nil
+}
+
+// copyElements copies the elements of a slice or array into another one of the same length
+func (c *cleaner) copyElements(copied reflect.Value, value reflect.Value, path string) error {
+	for i := 0; i < value.Len(); i++ {
+		elem, err := c.copyValue(value.Index(i), indexPath(path, i))
+		if err != nil {
+			return err
+		}
+		copied.Index(i).Set(elem)
+	}
+
+	return nil
+}
@@ -0,0 +1,120 @@
+package gopex
+
+import (
+	"database/sql"
+	"math"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// Type with a custom JSON unmarshaler that would not survive a JSON round trip
+type upperString string
+
+// UnmarshalJSON uppercases the string
+func (s *upperString) UnmarshalJSON(data []byte) error {
+	*s = upperString(strings.ToUpper(string(data)))
+	return nil
+}
+
+// Struct with values that are lost in a JSON round trip
+type OStruct struct {
+	ID       int64          `pex:"guest:r,user:r,sys:w,admin:rw"`
+	Code     upperString    `pex:"guest:r,user:r,sys:w,admin:rw"`
+	Created  time.Time      `pex:"guest:,user:r,sys:w,admin:rw"`
+	Deleted  sql.NullString `pex:"guest:,user:r,sys:w,admin:rw"`
+	Channel  chan int       `pex:"guest:,user:r,sys:w,admin:rw"`
+	Value    interface{}    `pex:"guest:,user:r,sys:w,admin:rw"`
+	Next     *OStruct       `pex:"guest:r,user:r,sys:w,admin:rw"`
+	Children map[string]*OStruct
+	secret   string
+}
+
+func TestCleanObjectCopy(t *testing.T) {
+	t.Run("TestCleanObjectCopyValues", testCleanObjectCopyValues)
+	t.Run("TestCleanObjectCopyPointers", testCleanObjectCopyPointers)
+}
+
+func testCleanObjectCopyValues(t *testing.T) {
+	t.Parallel()
+
+	created := time.Now()
+	channel := make(chan int)
+	baseOStruct := OStruct{
+		ID:      math.MaxInt64,
+		Code:    "abc",
+		Created: created,
+		Deleted: sql.NullString{String: "yes", Valid: true},
+		Channel: channel,
+		Value:   GStruct{Name: "ABC", Version: 1},
+		secret:  "DEF",
+	}
+
+	tables := []struct {
+		object   interface{}
+		userType string
+		action   uint
+		expected interface{}
+	}{
+		{baseOStruct, "guest", ActionRead, &OStruct{ID: math.MaxInt64, Code: "abc", secret: "DEF"}},
+		{baseOStruct, "user", ActionRead, &OStruct{
+			ID:      math.MaxInt64,
+			Code:    "abc",
+			Created: created,
+			Deleted: sql.NullString{String: "yes", Valid: true},
+			Channel: channel,
+			Value:   GStruct{Name: "ABC"},
+			secret:  "DEF",
+		}},
+		{baseOStruct, "sys", ActionRead, &OStruct{secret: "DEF"}},
+		{&baseOStruct, "sys", ActionWrite, &OStruct{
+			ID:      math.MaxInt64,
+			Code:    "abc",
+			Created: created,
+			Deleted: sql.NullString{String: "yes", Valid: true},
+			Channel: channel,
+			Value:   GStruct{Name: "ABC"},
+			secret:  "DEF",
+		}},
+	}
+
+	for _, table := range tables {
+		actual := CleanObject(table.object, table.userType, table.action)
+		if !reflect.DeepEqual(actual, table.expected) {
+			t.Errorf("%s (object = %+v, userType = %s, action = %d) was incorrect, got: %+v, want: %+v.",
+				t.Name(), table.object, table.userType, table.action, actual, table.expected)
+		}
+	}
+}
+
+func testCleanObjectCopyPointers(t *testing.T) {
+	t.Parallel()
+
+	shared := &OStruct{ID: 2, secret: "shared"}
+	cycle := &OStruct{ID: 1, Next: shared, Children: map[string]*OStruct{"a": shared}}
+	shared.Next = cycle
+
+	actual, ok := CleanObject(cycle, "guest", ActionRead).(*OStruct)
+	if !ok {
+		t.Fatalf("%s was incorrect, got: %T, want: *OStruct.", t.Name(), actual)
+	}
+
+	if actual == cycle || actual.Next == shared {
+		t.Errorf("%s was incorrect, the pointers were not copied.", t.Name())
+	}
+	if actual.ID != 1 || actual.Next.ID != 2 || actual.Next.secret != "shared" {
+		t.Errorf("%s was incorrect, got: %+v, want the values to be copied.", t.Name(), actual)
+	}
+	if actual.Next.Next != actual || actual.Children["a"] != actual.Next {
+		t.Errorf("%s was incorrect, the aliasing of the pointers was not kept.", t.Name())
+	}
+	self := map[string]interface{}{"a": AStruct{Number: 1}}
+	self["self"] = self
+	copied, err := CleanObjectE(self, "guest", ActionRead)
+	copiedMap, ok := copied.(*map[string]interface{})
+	if err != nil || !ok || (*copiedMap)["a"] != (AStruct{}) ||
+		reflect.ValueOf((*copiedMap)["self"]).Pointer() != reflect.ValueOf(*copiedMap).Pointer() {
+		t.Errorf("%s was incorrect, got: %v, want the self reference of the map to be kept.", t.Name(), err)
+	}
+}
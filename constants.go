@@ -3,6 +3,23 @@ package gopex
 // PermissionTag is the tag to use in structs to specify the permissions of each field
 const PermissionTag = "pex"
 
+// PermissionWildcard is the user type of the entry of a permission tag that applies to every role
+// not listed in it, nor inheriting from a listed one, like "*:r,admin:rw"
+const PermissionWildcard = "*"
+
+// Struct annotations, set as the permission tag of a blank field like _ struct{} `pex:"closed"`
+const (
+	// AnnotationOpen makes the untagged fields of a struct accessible, even to a strict Extractor
+	AnnotationOpen = "open"
+	// AnnotationClosed makes the untagged fields of a struct inaccessible, even to a non strict Extractor
+	AnnotationClosed = "closed"
+)
+
+// Action identifies an action performed on an object, like ActionRead or ActionWrite. More actions can
+// be added with RegisterAction. It is an alias of uint so that actions can be used with every function
+// of the package.
+type Action = uint
+
 // Actions
 const (
 	// ActionRead is used when the action is writing
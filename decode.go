@@ -0,0 +1,113 @@
+package gopex
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"reflect"
+)
+
+// DecodeOption configures how DecodeJSON handles the keys of a request body
+type DecodeOption func(*jsonDecoder)
+
+// StripForbidden makes DecodeJSON ignore the keys of fields the user has not permission to write instead
+// of returning a *ForbiddenError
+func StripForbidden() DecodeOption {
+	return func(d *jsonDecoder) {
+		d.strip = true
+	}
+}
+
+// AllowUnknownFields makes DecodeJSON ignore the keys that do not match any field, like encoding/json
+// does, instead of returning an *UnknownFieldsError
+func AllowUnknownFields() DecodeOption {
+	return func(d *jsonDecoder) {
+		d.allowUnknown = true
+	}
+}
+
+// jsonDecoder is the configuration of DecodeJSON
+type jsonDecoder struct {
+	// strip is true if the forbidden keys are ignored
+	strip bool
+	// allowUnknown is true if the unknown keys are ignored
+	allowUnknown bool
+}
+
+// DecodeJSON decodes the JSON body of a request into dst, which must be a non nil pointer, checking
+// that the user has permission to write every field it sets, including the ones of nested objects and
+// arrays. It returns:
+//
+//   - a *ForbiddenError listing every key of a field the user has not permission to write, unless the
+//     StripForbidden option is given
+//   - an *UnknownFieldsError listing every key that does not match any field, unless the
+//     AllowUnknownFields option is given
+//   - an *Error if the body is not a single valid JSON document or can not be decoded into dst, or
+//     wrapping ErrDuplicateKey if an object has keys that only differ in case for the same field, which
+//     encoding/json would resolve to the last one
+//
+// Nothing is decoded if a *ForbiddenError or an *UnknownFieldsError is returned.
+func DecodeJSON(r *http.Request, dst interface{}, userType string, options ...DecodeOption) error {
+	return defaultExtractor.DecodeJSONFor(r, dst, NewPrincipal(userType), options...)
+}
+
+// DecodeJSONFor is like DecodeJSON but for a principal that can hold several roles
+func DecodeJSONFor(r *http.Request, dst interface{}, principal Principal, options ...DecodeOption) error {
+	return defaultExtractor.DecodeJSONFor(r, dst, principal, options...)
+}
+
+// DecodeJSON decodes the JSON body of a request into dst checking the permissions of the user, like the
+// DecodeJSON function
+func (e *Extractor) DecodeJSON(r *http.Request, dst interface{}, userType string, options ...DecodeOption) error {
+	return e.DecodeJSONFor(r, dst, NewPrincipal(userType), options...)
+}
+
+// DecodeJSONFor is like DecodeJSON but for a principal that can hold several roles
+func (e *Extractor) DecodeJSONFor(r *http.Request, dst interface{}, principal Principal,
+	options ...DecodeOption) error {
+	decoder := &jsonDecoder{}
+	for _, option := range options {
+		option(decoder)
+	}
+
+	reflectValue := reflect.ValueOf(dst)
+	if reflectValue.Kind() != reflect.Ptr || reflectValue.IsNil() {
+		return &Error{Err: fmt.Errorf("%w %T", ErrNotPointer, dst)}
+	}
+	rootType := reflectValue.Type().Elem()
+	for rootType.Kind() == reflect.Ptr {
+		rootType = rootType.Elem()
+	}
+
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		return &Error{Err: err}
+	}
+	payload, err := decodePayload(body)
+	if err != nil {
+		return err
+	}
+
+	filter := &payloadFilter{extractor: e, principal: principal, action: ActionWrite}
+	filtered, err := filter.filterValue(payload, rootType, rootType.Name(), "")
+	if err != nil {
+		return err
+	}
+	if len(filter.forbidden) > 0 && !decoder.strip {
+		return &ForbiddenError{Violations: newViolations(filter.forbidden, principal, ActionWrite)}
+	}
+	if len(filter.unknown) > 0 && !decoder.allowUnknown {
+		return &UnknownFieldsError{Fields: filter.unknown}
+	}
+
+	data, err := json.Marshal(filtered)
+	if err != nil {
+		return &Error{Err: err}
+	}
+	if err := json.Unmarshal(data, dst); err != nil {
+		return &Error{Path: rootType.Name(), Err: err}
+	}
+
+	return nil
+}
@@ -0,0 +1,98 @@
+package gopex
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestDecodeJSON(t *testing.T) {
+	t.Run("TestDecodeJSONValid", testDecodeJSONValid)
+	t.Run("TestDecodeJSONErrors", testDecodeJSONErrors)
+}
+
+func testDecodeJSONValid(t *testing.T) {
+	t.Parallel()
+
+	tables := []struct {
+		body     string
+		userType string
+		options  []DecodeOption
+		expected ADStruct
+	}{
+		{`{"name":"ABC","addresses":[{"street":"DEF"}],"main":{"street":"GHI"}}`, "user", nil,
+			ADStruct{Name: "ABC", Addresses: []ACStruct{{Street: "DEF"}}, Main: &ACStruct{Street: "GHI"}}},
+		{`{"name":"ABC","salary":10,"addresses":[{"street":"DEF","verified":true}]}`, "user",
+			[]DecodeOption{StripForbidden()}, ADStruct{Name: "ABC", Addresses: []ACStruct{{Street: "DEF"}}}},
+		{`{"name":"ABC","salary":10,"unknown":true}`, "admin", []DecodeOption{AllowUnknownFields()},
+			ADStruct{Name: "ABC", Salary: 10}},
+		{`{"Name":"ABC","Version":1,"main":{"other":1}}`, "user",
+			[]DecodeOption{StripForbidden(), AllowUnknownFields()}, ADStruct{GStruct: GStruct{Version: 1},
+				Main: &ACStruct{}}},
+	}
+
+	for _, table := range tables {
+		request := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(table.body))
+
+		var actual ADStruct
+		err := DecodeJSON(request, &actual, table.userType, table.options...)
+		if err != nil || !reflect.DeepEqual(actual, table.expected) {
+			t.Errorf("%s (body = %s, userType = %s) was incorrect, got: %+v, %v, want: %+v.", t.Name(), table.body,
+				table.userType, actual, err, table.expected)
+		}
+	}
+}
+
+func testDecodeJSONErrors(t *testing.T) {
+	t.Parallel()
+
+	tables := []struct {
+		body     string
+		dst      interface{}
+		options  []DecodeOption
+		expected error
+		fields   []string
+	}{
+		{`{"name":"ABC","salary":10,"main":{"verified":true},"unknown":1}`, &ADStruct{}, nil, ErrForbidden,
+			[]string{"/main/verified", "/salary"}},
+		{`{"name":"ABC","main":{"verifed":true},"unknown":1}`, &ADStruct{}, nil, ErrUnknownField,
+			[]string{"/main/verifed", "/unknown"}},
+		{`{"name":"ABC","salary":10,"unknown":1}`, &ADStruct{}, []DecodeOption{StripForbidden()}, ErrUnknownField,
+			[]string{"/unknown"}},
+		{`{"name":`, &ADStruct{}, nil, nil, nil},
+		{`{"name":1}`, &ADStruct{}, nil, nil, nil},
+		{`{"name":"ABC"} {"main":{"verified":true}}`, &ADStruct{}, nil, nil, nil},
+		{`{"name":"ABC"}}`, &ADStruct{}, nil, nil, nil},
+		{`{"name":"ABC","NAME":"DEF"}`, &ADStruct{}, nil, ErrDuplicateKey, nil},
+		{`{"name":"ABC"}`, ADStruct{}, nil, ErrNotPointer, nil},
+	}
+
+	for _, table := range tables {
+		request := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(table.body))
+		err := DecodeJSON(request, table.dst, "user", table.options...)
+
+		var fields []string
+		var unknownErr *UnknownFieldsError
+		if errors.As(err, &unknownErr) {
+			fields = unknownErr.Fields
+		} else {
+			fields = violationPaths(err)
+		}
+
+		var pexErr *Error
+		if table.expected == nil && !errors.As(err, &pexErr) ||
+			table.expected != nil && !errors.Is(err, table.expected) || !reflect.DeepEqual(fields, table.fields) {
+			t.Errorf("%s (body = %s) was incorrect, got: %v, want: %v %v.", t.Name(), table.body, err, table.expected,
+				table.fields)
+		}
+	}
+
+	var untouched ADStruct
+	request := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(`{"name":"ABC","salary":10}`))
+	if err := DecodeJSON(request, &untouched, "user"); err == nil || untouched.Name != "" {
+		t.Errorf("%s was incorrect, got: %+v, %v, want: %+v.", t.Name(), untouched, err, ADStruct{})
+	}
+}
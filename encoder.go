@@ -0,0 +1,274 @@
+package gopex
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"reflect"
+	"sort"
+)
+
+// Encoder writes the fields a user has access for an action as JSON to a stream, walking the values as
+// they are written instead of extracting them first. Besides the values ExtractFields supports, channels
+// and iterator functions like func(yield func(T) bool) are written as JSON arrays, receiving or
+// iterating their elements one by one, so that any number of objects can be written with constant memory.
+type Encoder struct {
+	writer    *bufio.Writer
+	extractor *Extractor
+	principal Principal
+	action    uint
+}
+
+// NewEncoder returns an Encoder that writes to w the fields a given user have access for that action
+func NewEncoder(w io.Writer, userType string, action uint) *Encoder {
+	return defaultExtractor.NewEncoderFor(w, NewPrincipal(userType), action)
+}
+
+// NewEncoderFor is like NewEncoder but for a principal that can hold several roles
+func NewEncoderFor(w io.Writer, principal Principal, action uint) *Encoder {
+	return defaultExtractor.NewEncoderFor(w, principal, action)
+}
+
+// NewEncoder returns an Encoder that writes to w the fields a given user have access for that action,
+// like the NewEncoder function
+func (e *Extractor) NewEncoder(w io.Writer, userType string, action uint) *Encoder {
+	return e.NewEncoderFor(w, NewPrincipal(userType), action)
+}
+
+// NewEncoderFor is like NewEncoder but for a principal that can hold several roles
+func (e *Extractor) NewEncoderFor(w io.Writer, principal Principal, action uint) *Encoder {
+	return &Encoder{writer: bufio.NewWriter(w), extractor: e, principal: principal, action: action}
+}
+
+// Encode writes the JSON encoding of the fields of v the user has access, followed by a newline, like
+// json.Encoder does. The fields of structs are written in declaration order and the keys of maps sorted.
+// It stops at the first error, returning an *Error, in which case the stream has a partial value.
+func (enc *Encoder) Encode(v interface{}) error {
+	if err := enc.encodeValue(reflect.ValueOf(v), rootPath(v)); err != nil {
+		_ = enc.writer.Flush()
+		return err
+	}
+
+	_ = enc.writer.WriteByte('\n')
+	if err := enc.writer.Flush(); err != nil {
+		return &Error{Err: err}
+	}
+	return nil
+}
+
+// encodeValue writes the fields of any kind of value
+func (enc *Encoder) encodeValue(value reflect.Value, path string) error {
+	for value.Kind() == reflect.Ptr || value.Kind() == reflect.Interface {
+		if value.IsNil() {
+			_, err := enc.writer.WriteString("null")
+			return err
+		}
+		value = value.Elem()
+	}
+	if !value.IsValid() {
+		_, err := enc.writer.WriteString("null")
+		return err
+	}
+
+	if enc.extractor.isLeaf(value.Type()) {
+		result, err := enc.extractor.getSpecialObjectValue(value, path)
+		if err != nil {
+			return err
+		}
+		return enc.encodeJSON(result, path)
+	}
+
+	switch value.Kind() {
+	case reflect.Struct:
+		return enc.encodeStruct(value, path)
+	case reflect.Slice, reflect.Array:
+		return enc.encodeElements(path, func(yield func(reflect.Value, string) error) error {
+			for i := 0; i < value.Len(); i++ {
+				if err := yield(value.Index(i), indexPath(path, i)); err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+	case reflect.Map:
+		return enc.encodeMap(value, path)
+	case reflect.Chan:
+		if value.Type().ChanDir()&reflect.RecvDir == 0 {
+			break
+		}
+		return enc.encodeElements(path, func(yield func(reflect.Value, string) error) error {
+			for i := 0; ; i++ {
+				elem, ok := value.Recv()
+				if !ok {
+					return nil
+				}
+				if err := yield(elem, indexPath(path, i)); err != nil {
+					return err
+				}
+			}
+		})
+	case reflect.Func:
+		if !isIterator(value.Type()) {
+			break
+		}
+		return enc.encodeElements(path, func(yield func(reflect.Value, string) error) error {
+			var err error
+			i := 0
+			yieldType := value.Type().In(0)
+			value.Call([]reflect.Value{reflect.MakeFunc(yieldType, func(args []reflect.Value) []reflect.Value {
+				err = yield(args[0], indexPath(path, i))
+				i++
+				return []reflect.Value{reflect.ValueOf(err == nil)}
+			})})
+			return err
+		})
+	case reflect.UnsafePointer, reflect.Complex64, reflect.Complex128:
+	default:
+		return enc.encodeJSON(value.Interface(), path)
+	}
+
+	return &Error{Path: path, Err: fmt.Errorf("%w %s", ErrUnsupportedKind, value.Type())}
+}
+
+// encodeStruct writes the fields of a struct the user has access
+func (enc *Encoder) encodeStruct(value reflect.Value, path string) error {
+	if err := enc.writer.WriteByte('{'); err != nil {
+		return &Error{Path: path, Err: err}
+	}
+
+	first := true
+	for _, field := range enc.extractor.plans.get(value.Type()).outputs {
+		fieldValue, ok := fieldByIndex(value, field.index)
+		if !ok {
+			continue
+		}
+
+		fieldPath := fieldPath(path, field.path)
+		allowed, err := field.allows(enc.extractor, enc.principal, enc.action)
+		if err != nil {
+			return &Error{Path: fieldPath, Err: err}
+		}
+		if !allowed || field.omitEmpty && isEmptyValue(fieldValue) {
+			continue
+		}
+
+		if err := enc.encodeKey(field.name, first, fieldPath); err != nil {
+			return err
+		}
+		first = false
+
+		if field.quoted {
+			result, err := quoteValue(fieldValue)
+			if err != nil {
+				return &Error{Path: fieldPath, Err: err}
+			}
+			err = enc.encodeJSON(result, fieldPath)
+		} else {
+			err = enc.encodeValue(fieldValue, fieldPath)
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	if err := enc.writer.WriteByte('}'); err != nil {
+		return &Error{Path: path, Err: err}
+	}
+	return nil
+}
+
+// encodeMap writes the values of a map, sorted by key
+func (enc *Encoder) encodeMap(value reflect.Value, path string) error {
+	keys := make([]string, 0, value.Len())
+	values := make(map[string]reflect.Value, value.Len())
+	for _, key := range value.MapKeys() {
+		keyName, err := getMapKeyName(key)
+		if err != nil {
+			return &Error{Path: path + "[" + fmt.Sprint(key.Interface()) + "]", Err: err}
+		}
+		keys = append(keys, keyName)
+		values[keyName] = value.MapIndex(key)
+	}
+	sort.Strings(keys)
+
+	if err := enc.writer.WriteByte('{'); err != nil {
+		return &Error{Path: path, Err: err}
+	}
+	for i, key := range keys {
+		if err := enc.encodeKey(key, i == 0, path+"["+key+"]"); err != nil {
+			return err
+		}
+		if err := enc.encodeValue(values[key], path+"["+key+"]"); err != nil {
+			return err
+		}
+	}
+	if err := enc.writer.WriteByte('}'); err != nil {
+		return &Error{Path: path, Err: err}
+	}
+	return nil
+}
+
+// encodeElements writes the elements produced by an iteration as a JSON array
+func (enc *Encoder) encodeElements(path string, iterate func(yield func(reflect.Value, string) error) error) error {
+	if err := enc.writer.WriteByte('['); err != nil {
+		return &Error{Path: path, Err: err}
+	}
+
+	first := true
+	err := iterate(func(elem reflect.Value, elemPath string) error {
+		if !first {
+			if err := enc.writer.WriteByte(','); err != nil {
+				return &Error{Path: elemPath, Err: err}
+			}
+		}
+		first = false
+		return enc.encodeValue(elem, elemPath)
+	})
+	if err != nil {
+		return err
+	}
+
+	if err := enc.writer.WriteByte(']'); err != nil {
+		return &Error{Path: path, Err: err}
+	}
+	return nil
+}
+
+// encodeKey writes the key of an object member, preceded by a comma if it is not the first one
+func (enc *Encoder) encodeKey(key string, first bool, path string) error {
+	if !first {
+		if err := enc.writer.WriteByte(','); err != nil {
+			return &Error{Path: path, Err: err}
+		}
+	}
+	if err := enc.encodeJSON(key, path); err != nil {
+		return err
+	}
+	if err := enc.writer.WriteByte(':'); err != nil {
+		return &Error{Path: path, Err: err}
+	}
+	return nil
+}
+
+// encodeJSON writes a value encoded with encoding/json
+func (enc *Encoder) encodeJSON(v interface{}, path string) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return &Error{Path: path, Err: err}
+	}
+	if _, err := enc.writer.Write(data); err != nil {
+		return &Error{Path: path, Err: err}
+	}
+	return nil
+}
+
+// isIterator returns true if a function type is an iterator like func(yield func(T) bool)
+func isIterator(reflectType reflect.Type) bool {
+	if reflectType.NumIn() != 1 || reflectType.NumOut() != 0 || reflectType.In(0).Kind() != reflect.Func {
+		return false
+	}
+
+	yieldType := reflectType.In(0)
+	return yieldType.NumIn() == 1 && yieldType.NumOut() == 1 && yieldType.Out(0).Kind() == reflect.Bool
+}
@@ -0,0 +1,134 @@
+package gopex
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestEncoder(t *testing.T) {
+	t.Run("TestEncoderValues", testEncoderValues)
+	t.Run("TestEncoderStreams", testEncoderStreams)
+	t.Run("TestEncoderErrors", testEncoderErrors)
+}
+
+func testEncoderValues(t *testing.T) {
+	t.Parallel()
+
+	start := time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)
+	tables := []struct {
+		object   interface{}
+		userType string
+		action   uint
+	}{
+		{AStruct{Number: 1, Text: "ABC"}, "user", ActionRead},
+		{&BStruct{AStruct: AStruct{Number: 1}, Boolean: true}, "admin", ActionWrite},
+		{CStruct{Pointer: &AStruct{Number: 2}, Interface: AStruct{Text: "DEF"}}, "user", ActionRead},
+		{EStruct{Start: start, Stop: &start}, "user", ActionRead},
+		{[]FStruct{{Name: "ABC", Slice: []AStruct{{Number: 1}}}, {}}, "user", ActionRead},
+		{map[string]GStruct{"b": {Name: "ABC"}, "a": {Version: 1}}, "guest", ActionRead},
+		{ADStruct{Name: "ABC", Contacts: map[string]ACStruct{"home": {Street: "DEF"}}}, "user", ActionRead},
+		{[]interface{}{nil, 1, "ABC", &AStruct{}}, "user", ActionRead},
+		{(*AStruct)(nil), "user", ActionRead},
+		{nil, "user", ActionRead},
+	}
+
+	for _, table := range tables {
+		var buffer bytes.Buffer
+		err := NewEncoder(&buffer, table.userType, table.action).Encode(table.object)
+
+		extracted, _ := ExtractFieldsE(table.object, table.userType, table.action)
+		expected, _ := json.Marshal(extracted)
+
+		// Struct fields are written in declaration order, so compare the decoded documents
+		var actual, want interface{}
+		decodeErr := json.Unmarshal(buffer.Bytes(), &actual)
+		_ = json.Unmarshal(expected, &want)
+		if err != nil || decodeErr != nil || !reflect.DeepEqual(actual, want) {
+			t.Errorf("%s (object = %+v, userType = %s, action = %d) was incorrect, got: %s, %v, want: %s.", t.Name(),
+				table.object, table.userType, table.action, buffer.String(), err, expected)
+		}
+	}
+}
+
+func testEncoderStreams(t *testing.T) {
+	t.Parallel()
+
+	objects := []AStruct{{Number: 1, Text: "ABC"}, {Number: 2}, {Text: "DEF"}}
+	channel := make(chan AStruct, len(objects))
+	for _, object := range objects {
+		channel <- object
+	}
+	close(channel)
+
+	iterator := func(yield func(*AStruct) bool) {
+		for i := range objects {
+			if !yield(&objects[i]) {
+				return
+			}
+		}
+	}
+
+	tables := []struct {
+		stream   interface{}
+		expected string
+	}{
+		{channel, `[{"Number":1,"Label":"ABC"},{"Number":2,"Label":""},{"Number":0,"Label":"DEF"}]`},
+		{(<-chan AStruct)(channel), `[]`},
+		{iterator, `[{"Number":1,"Label":"ABC"},{"Number":2,"Label":""},{"Number":0,"Label":"DEF"}]`},
+		{struct{ Rows func(func(AStruct) bool) }{func(func(AStruct) bool) {}}, `{"Rows":[]}`},
+	}
+
+	for _, table := range tables {
+		var buffer bytes.Buffer
+		err := NewEncoderFor(&buffer, NewPrincipal("user"), ActionRead).Encode(table.stream)
+		if err != nil || buffer.String() != table.expected+"\n" {
+			t.Errorf("%s (stream = %T) was incorrect, got: %s, %v, want: %s.", t.Name(), table.stream,
+				buffer.String(), err, table.expected)
+		}
+	}
+}
+
+func testEncoderErrors(t *testing.T) {
+	t.Parallel()
+
+	yielded := 0
+	iterator := func(yield func(interface{}) bool) {
+		for _, object := range []interface{}{AStruct{}, complex(1, 2), AStruct{}} {
+			yielded++
+			if !yield(object) {
+				return
+			}
+		}
+	}
+
+	tables := []struct {
+		object   interface{}
+		action   uint
+		expected error
+		path     string
+	}{
+		{JStruct{}, ActionRead, ErrMalformedTag, "JStruct.Version"},
+		{AStruct{}, 99, ErrUnknownAction, "AStruct.Number"},
+		{[]interface{}{AStruct{}, func() {}}, ActionRead, ErrUnsupportedKind, "[1]"},
+		{iterator, ActionRead, ErrUnsupportedKind, "[1]"},
+	}
+
+	for _, table := range tables {
+		var buffer bytes.Buffer
+		err := NewEncoder(&buffer, "user", table.action).Encode(table.object)
+
+		var pexErr *Error
+		if !errors.Is(err, table.expected) || !errors.As(err, &pexErr) || pexErr.Path != table.path {
+			t.Errorf("%s (object = %T, action = %d) was incorrect, got: %v, want: %v at %s.", t.Name(),
+				table.object, table.action, err, table.expected, table.path)
+		}
+	}
+
+	if yielded != 2 {
+		t.Errorf("%s (iterator) was incorrect, got: %d yielded, want: 2.", t.Name(), yielded)
+	}
+}
@@ -0,0 +1,134 @@
+package gopex
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+)
+
+// Errors reported by the error-returning functions of the package
+var (
+	// ErrUnsupportedKind is reported when a value of a kind that can not be represented is found
+	ErrUnsupportedKind = errors.New("unsupported kind")
+	// ErrMalformedTag is reported when a permission tag can not be parsed
+	ErrMalformedTag = errors.New("malformed permission tag")
+	// ErrNotPointer is reported when a function that modifies an object does not receive a non nil pointer
+	ErrNotPointer = errors.New("not a non nil pointer")
+	// ErrUnknownAction is reported when an action that is not registered is used
+	ErrUnknownAction = errors.New("unknown action")
+	// ErrInvalidAction is reported when an action can not be registered
+	ErrInvalidAction = errors.New("invalid action")
+	// ErrTypeMismatch is reported when a value is not of the expected type
+	ErrTypeMismatch = errors.New("type mismatch")
+	// ErrForbidden is reported when a principal attempts an action on fields it has not permission
+	ErrForbidden = errors.New("forbidden")
+	// ErrInvalidPatch is reported when a JSON Patch document is malformed
+	ErrInvalidPatch = errors.New("invalid patch")
+	// ErrUnknownField is reported when a JSON document has keys that do not match any field
+	ErrUnknownField = errors.New("unknown field")
+	// ErrNoPrincipal is reported when a context does not carry a principal
+	ErrNoPrincipal = errors.New("no principal")
+	// ErrRoleCycle is reported when a role would inherit from itself
+	ErrRoleCycle = errors.New("role cycle")
+	// ErrDuplicateKey is reported when a JSON object has several keys for the same field, like "name" and "Name"
+	ErrDuplicateKey = errors.New("duplicate key")
+)
+
+// Error is the error returned when an object can not be processed. Path is the struct path
+// of the value that caused the error, like "Employee.Addresses[2].Street".
+type Error struct {
+	Path string
+	Err  error
+}
+
+// Error returns the description of the error
+func (e *Error) Error() string {
+	if e.Path == "" {
+		return "gopex: " + e.Err.Error()
+	}
+
+	// The path already locates a malformed tag, so it is not repeated
+	if tagErr, ok := e.Err.(*TagError); ok {
+		return "gopex: " + e.Path + ": " + tagErr.describe("")
+	}
+
+	return "gopex: " + e.Path + ": " + e.Err.Error()
+}
+
+// Unwrap returns the underlying error
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// Violation is a field a principal attempted an action on without permission
+type Violation struct {
+	// Path is the JSON pointer of the field, like "/addresses/2/verified"
+	Path string `json:"path"`
+	// Roles are the roles of the principal
+	Roles []string `json:"roles"`
+	// Permission is the name of the action the principal has not permission for, like "write"
+	Permission string `json:"permission"`
+}
+
+// ForbiddenError is the error returned when a principal attempts an action on fields it has not
+// permission. It lists every violation and can be encoded as JSON to be used as the body of an
+// HTTP 403 response.
+type ForbiddenError struct {
+	Violations []Violation `json:"violations"`
+}
+
+// newViolations returns the violations of the fields at the JSON pointers
+func newViolations(pointers []string, principal Principal, action uint) []Violation {
+	violations := make([]Violation, len(pointers))
+	for i, pointer := range pointers {
+		violations[i] = Violation{
+			Path:       pointer,
+			Roles:      append([]string{}, principal.Roles...),
+			Permission: ActionName(action),
+		}
+	}
+
+	return violations
+}
+
+// Error returns the description of the error
+func (e *ForbiddenError) Error() string {
+	paths := make([]string, len(e.Violations))
+	for i, violation := range e.Violations {
+		paths[i] = violation.Path
+	}
+
+	return "gopex: " + ErrForbidden.Error() + ": " + strings.Join(paths, ", ")
+}
+
+// Unwrap returns ErrForbidden so that errors.Is can be used to detect forbidden actions
+func (e *ForbiddenError) Unwrap() error {
+	return ErrForbidden
+}
+
+// StatusCode returns the HTTP status code of the error, http.StatusForbidden
+func (e *ForbiddenError) StatusCode() int {
+	return http.StatusForbidden
+}
+
+// UnknownFieldsError is the error returned when a JSON document has keys that do not match any field of
+// the type it is decoded into. It can be encoded as JSON to be used as the body of an HTTP 400 response.
+type UnknownFieldsError struct {
+	// Fields are the JSON pointers of the keys, like "/addresses/2/verifed"
+	Fields []string `json:"unknownFields"`
+}
+
+// Error returns the description of the error
+func (e *UnknownFieldsError) Error() string {
+	return "gopex: " + ErrUnknownField.Error() + ": " + strings.Join(e.Fields, ", ")
+}
+
+// Unwrap returns ErrUnknownField so that errors.Is can be used to detect unknown fields
+func (e *UnknownFieldsError) Unwrap() error {
+	return ErrUnknownField
+}
+
+// StatusCode returns the HTTP status code of the error, http.StatusBadRequest
+func (e *UnknownFieldsError) StatusCode() int {
+	return http.StatusBadRequest
+}
@@ -0,0 +1,255 @@
+package gopex
+
+import (
+	"fmt"
+	"reflect"
+	"time"
+)
+
+// Extractor extracts, cleans and redacts objects with a given configuration. The functions of the
+// package use an Extractor with the default configuration.
+type Extractor struct {
+	// tagName is the name of the permission tag
+	tagName string
+	// namingTag is the name of the tag with the names and options of the fields
+	namingTag string
+	// leafTypes are the leaf types of the Extractor and their converters, on top of the ones of the package
+	leafTypes map[reflect.Type]LeafConverter
+	// timeLayout is the layout used to format times
+	timeLayout string
+	// unixTime is true if times are extracted as Unix timestamps in seconds
+	unixTime bool
+	// roles is the role hierarchy used to resolve the roles missing from permission tags
+	roles *RoleHierarchy
+	// defaultPolicy are the actions allowed to the roles that can not be resolved in a permission tag
+	defaultPolicy actionMask
+	// strict is true if untagged fields are not accessible, unless their struct is annotated as open
+	strict bool
+	// ordered is true if structs are extracted as an OrderedObject
+	ordered bool
+	// plans is the cache of struct plans
+	plans *planCache
+}
+
+// Option configures an Extractor
+type Option func(*Extractor)
+
+// WithTagName sets the name of the permission tag, by default PermissionTag
+func WithTagName(name string) Option {
+	return func(e *Extractor) {
+		e.tagName = name
+	}
+}
+
+// WithNamingTag sets the name of the tag with the names of the fields in the extracted objects and their
+// options, like yaml or bson. By default it is json. The tag is interpreted like encoding/json does,
+// with "-" skipping a field and the inline, omitempty and string options.
+func WithNamingTag(name string) Option {
+	return func(e *Extractor) {
+		e.namingTag = name
+	}
+}
+
+// WithLeafType registers a leaf type only for the Extractor, like RegisterLeafType. It takes precedence
+// over the leaf types registered in the package.
+func WithLeafType(reflectType reflect.Type, convert LeafConverter) Option {
+	return func(e *Extractor) {
+		if convert == nil {
+			convert = func(value interface{}) (interface{}, error) {
+				return value, nil
+			}
+		}
+
+		if e.leafTypes == nil {
+			e.leafTypes = map[reflect.Type]LeafConverter{}
+		}
+		e.leafTypes[reflectType] = convert
+	}
+}
+
+// WithRoleHierarchy sets the role hierarchy of the Extractor. By default it is the one of the package,
+// defined with DefineRole.
+func WithRoleHierarchy(roles *RoleHierarchy) Option {
+	return func(e *Extractor) {
+		e.roles = roles
+	}
+}
+
+// WithTimeLayout sets the layout used to format times, as accepted by time.Time.Format.
+// By default times are formatted with time.RFC3339Nano, like encoding/json does.
+func WithTimeLayout(layout string) Option {
+	return func(e *Extractor) {
+		e.timeLayout = layout
+		e.unixTime = false
+	}
+}
+
+// WithUnixTime extracts times as the number of seconds elapsed since the Unix epoch
+func WithUnixTime() Option {
+	return func(e *Extractor) {
+		e.unixTime = true
+	}
+}
+
+// WithDefaultPolicy sets the actions allowed in tagged fields to the roles that are not listed in the
+// tag, do not inherit from a listed role and have no wildcard entry to fall back to. By default they
+// are not allowed any action.
+func WithDefaultPolicy(actions ...uint) Option {
+	return func(e *Extractor) {
+		e.defaultPolicy = 0
+		for _, action := range actions {
+			e.defaultPolicy |= 1 << action
+		}
+	}
+}
+
+// WithStrict makes untagged fields inaccessible to every role, so that fields are hidden until they are
+// tagged. The untagged fields of structs annotated as open are still accessible.
+func WithStrict() Option {
+	return func(e *Extractor) {
+		e.strict = true
+	}
+}
+
+// WithOrderedOutput extracts structs as an OrderedObject instead of a map[string]interface{}, so that
+// their fields are marshaled in declaration order like encoding/json does with the struct
+func WithOrderedOutput() Option {
+	return func(e *Extractor) {
+		e.ordered = true
+	}
+}
+
+// defaultExtractor is the Extractor used by the functions of the package
+var defaultExtractor = NewExtractor()
+
+// NewExtractor returns an Extractor configured with the given options
+func NewExtractor(options ...Option) *Extractor {
+	e := &Extractor{
+		tagName:    PermissionTag,
+		namingTag:  "json",
+		timeLayout: time.RFC3339Nano,
+		roles:      defaultRoles,
+	}
+	for _, option := range options {
+		option(e)
+	}
+	e.plans = newPlanCache(e.tagName, e.namingTag, e.isLeaf)
+
+	return e
+}
+
+// Extract extracts all the fields that a given user have access for that action, like ExtractFields,
+// returning an error if the object has values of unsupported kinds or malformed permission tags.
+func (e *Extractor) Extract(object interface{}, userType string, action uint) (interface{}, error) {
+	return e.ExtractFor(object, NewPrincipal(userType), action)
+}
+
+// ExtractFor is like Extract but for a principal that can hold several roles
+func (e *Extractor) ExtractFor(object interface{}, principal Principal, action uint) (interface{}, error) {
+	result, err := e.extract(object, principal, action)
+	if err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
+
+// Clean returns a pointer to a deep copy of the object where the fields the user has not access
+// for that action are set to their zero values, like CleanObjectE.
+func (e *Extractor) Clean(object interface{}, userType string, action uint) (interface{}, error) {
+	return e.CleanFor(object, NewPrincipal(userType), action)
+}
+
+// CleanFor is like Clean but for a principal that can hold several roles
+func (e *Extractor) CleanFor(object interface{}, principal Principal, action uint) (interface{}, error) {
+	// Get the reflect value, keeping the pointer to it if there is one
+	reflectValue := reflect.ValueOf(object)
+	pointer := reflect.Value{}
+	for reflectValue.Kind() == reflect.Ptr || reflectValue.Kind() == reflect.Interface {
+		pointer = reflect.Value{}
+		if reflectValue.Kind() == reflect.Ptr {
+			pointer = reflectValue
+		}
+		reflectValue = reflectValue.Elem()
+	}
+	if !reflectValue.IsValid() {
+		return nil, nil
+	}
+
+	// Copy the object to a new pointer
+	cleaner := &cleaner{extractor: e, principal: principal, action: action, copies: map[visit]reflect.Value{}}
+	if !pointer.IsValid() {
+		pointer = reflect.New(reflectValue.Type())
+		pointer.Elem().Set(reflectValue)
+	}
+	result, err := cleaner.copyValue(pointer, reflectValue.Type().Name())
+	if err != nil {
+		return nil, err
+	}
+
+	return result.Interface(), nil
+}
+
+// Redact sets in place every field the user has not access for that action to its zero value, like
+// the Redact function.
+func (e *Extractor) Redact(ptr interface{}, userType string, action uint) error {
+	return e.RedactFor(ptr, NewPrincipal(userType), action)
+}
+
+// RedactFor is like Redact but for a principal that can hold several roles
+func (e *Extractor) RedactFor(ptr interface{}, principal Principal, action uint) error {
+	reflectValue := reflect.ValueOf(ptr)
+	if reflectValue.Kind() != reflect.Ptr || reflectValue.IsNil() {
+		return &Error{Err: fmt.Errorf("%w %T", ErrNotPointer, ptr)}
+	}
+
+	redactor := &redactor{extractor: e, principal: principal, action: action, visited: map[visit]bool{}}
+	return redactor.redactValue(reflectValue, reflectValue.Type().Elem().Name())
+}
+
+// grants returns true if any of the roles of the principal has permission for the action in a permission
+// set. A role gets its entry in the set if it is listed, otherwise the entries of its ancestors, otherwise
+// the wildcard entry and, if there is none, the default policy.
+func (e *Extractor) grants(permissions permissionSet, principal Principal, action uint) bool {
+	for _, role := range principal.Roles {
+		mask, ok := e.roles.mask(permissions, role)
+		if !ok {
+			mask, ok = permissions[PermissionWildcard]
+		}
+		if !ok {
+			mask = e.defaultPolicy
+		}
+
+		if mask.has(action) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// extract extracts the fields of an object, returning the result even if there is an error
+func (e *Extractor) extract(object interface{}, principal Principal, action uint) (interface{}, error) {
+	return e.newExtraction(principal, action).extractFields(object, rootPath(object))
+}
+
+// formatTime returns the value of a time in the extracted object
+func (e *Extractor) formatTime(t time.Time) interface{} {
+	if e.unixTime {
+		return t.Unix()
+	}
+
+	return t.Format(e.timeLayout)
+}
+
+// extraction is the state of an extraction of fields
+type extraction struct {
+	extractor *Extractor
+	principal Principal
+	action    uint
+}
+
+// newExtraction returns an extraction of the fields a principal has access for an action
+func (e *Extractor) newExtraction(principal Principal, action uint) *extraction {
+	return &extraction{extractor: e, principal: principal, action: action}
+}
@@ -0,0 +1,311 @@
+package gopex
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestExtractorTime(t *testing.T) {
+	t.Run("TestExtractorTimeFormat", testExtractorTimeFormat)
+	t.Run("TestExtractorTimeRoundTrip", testExtractorTimeRoundTrip)
+}
+
+func testExtractorTimeFormat(t *testing.T) {
+	t.Parallel()
+
+	start := time.Date(2019, 1, 1, 10, 30, 0, 500, time.UTC)
+	baseEStruct := EStruct{Start: start, Stop: &start, Number: sql.NullInt64{Int64: 10, Valid: true}}
+
+	tables := []struct {
+		extractor *Extractor
+		expected  interface{}
+	}{
+		{NewExtractor(), map[string]interface{}{
+			"Start": "2019-01-01T10:30:00.0000005Z", "Stop": "2019-01-01T10:30:00.0000005Z", "Number": int64(10)}},
+		{NewExtractor(WithTimeLayout("2006-01-02")), map[string]interface{}{
+			"Start": "2019-01-01", "Stop": "2019-01-01", "Number": int64(10)}},
+		{NewExtractor(WithUnixTime()), map[string]interface{}{
+			"Start": int64(1546338600), "Stop": int64(1546338600), "Number": int64(10)}},
+		{NewExtractor(WithUnixTime(), WithTimeLayout(time.RFC822)), map[string]interface{}{
+			"Start": "01 Jan 19 10:30 UTC", "Stop": "01 Jan 19 10:30 UTC", "Number": int64(10)}},
+	}
+
+	for _, table := range tables {
+		actual, err := table.extractor.Extract(baseEStruct, "user", ActionRead)
+		if err != nil || !reflect.DeepEqual(actual, table.expected) {
+			t.Errorf("%s was incorrect, got: %+v, %v, want: %+v.", t.Name(), actual, err, table.expected)
+		}
+	}
+}
+
+func testExtractorTimeRoundTrip(t *testing.T) {
+	t.Parallel()
+
+	start := time.Now()
+	stop := start.Add(time.Hour).In(time.FixedZone("UTC+1", 3600))
+	baseEStruct := EStruct{Start: start, Stop: &stop}
+
+	// Times are kept exactly as they are when cleaning
+	cleaned := CleanObject(baseEStruct, "user", ActionRead).(*EStruct)
+	if cleaned.Start != start || *cleaned.Stop != stop || cleaned.Stop == &stop {
+		t.Errorf("%s was incorrect, got: %+v, want: %+v.", t.Name(), cleaned, baseEStruct)
+	}
+
+	// Extracted times can be decoded back into times
+	data, err := json.Marshal(ExtractFields(cleaned, "user", ActionRead))
+	if err != nil {
+		t.Fatalf("%s was incorrect, got: %v.", t.Name(), err)
+	}
+
+	var decoded EStruct
+	if err := json.Unmarshal(data, &decoded); err != nil || !decoded.Start.Equal(start) || !decoded.Stop.Equal(stop) {
+		t.Errorf("%s was incorrect, got: %+v, %v, want: %+v.", t.Name(), decoded, err, baseEStruct)
+	}
+}
+
+// Struct with wildcard permissions
+type XStruct struct {
+	Name     string `pex:"*:r,admin:rw"`
+	Email    string `pex:"user:rw"`
+	Password string `pex:"*:,admin:w"`
+	Notes    string
+}
+
+func TestExtractorDefaultPolicy(t *testing.T) {
+	t.Parallel()
+
+	roles := NewRoleHierarchy()
+	_ = roles.Define("manager", "user")
+	extractor := NewExtractor(WithDefaultPolicy(ActionRead), WithRoleHierarchy(roles))
+
+	baseXStruct := XStruct{Name: "ABC", Email: "DEF", Password: "GHI", Notes: "JKL"}
+	tables := []struct {
+		extractor *Extractor
+		userType  string
+		action    uint
+		expected  interface{}
+	}{
+		{defaultExtractor, "guest", ActionRead, map[string]interface{}{"Name": "ABC", "Notes": "JKL"}},
+		{defaultExtractor, "guest", ActionWrite, map[string]interface{}{"Notes": "JKL"}},
+		{defaultExtractor, "admin", ActionWrite, map[string]interface{}{"Name": "ABC", "Password": "GHI", "Notes": "JKL"}},
+		{defaultExtractor, "user", ActionWrite, map[string]interface{}{"Email": "DEF", "Notes": "JKL"}},
+		{extractor, "guest", ActionRead, map[string]interface{}{"Name": "ABC", "Email": "DEF", "Notes": "JKL"}},
+		{extractor, "guest", ActionWrite, map[string]interface{}{"Notes": "JKL"}},
+		{extractor, "manager", ActionWrite, map[string]interface{}{"Email": "DEF", "Notes": "JKL"}},
+		{NewExtractor(WithDefaultPolicy(ActionRead, ActionWrite)), "guest", ActionWrite,
+			map[string]interface{}{"Email": "DEF", "Notes": "JKL"}},
+	}
+
+	for _, table := range tables {
+		actual, err := table.extractor.Extract(baseXStruct, table.userType, table.action)
+		if err != nil || !reflect.DeepEqual(actual, table.expected) {
+			t.Errorf("%s (userType = %s, action = %d) was incorrect, got: %+v, %v, want: %+v.", t.Name(),
+				table.userType, table.action, actual, err, table.expected)
+		}
+	}
+}
+
+// Struct annotated as closed
+type YStruct struct {
+	_        struct{} `pex:"closed"`
+	Name     string   `pex:"user:r"`
+	Password string
+	Open     *openStruct
+	XStruct
+}
+
+// Struct annotated as open
+type openStruct struct {
+	_      struct{} `pex:"open"`
+	Name   string
+	Secret string `pex:"admin:r"`
+}
+
+// Struct with an untagged embedded struct
+type AFStruct struct {
+	XStruct
+	Title string `pex:"user:r"`
+}
+
+func TestExtractorStrict(t *testing.T) {
+	t.Parallel()
+
+	baseYStruct := YStruct{
+		Name:     "ABC",
+		Password: "DEF",
+		Open:     &openStruct{Name: "GHI", Secret: "JKL"},
+		XStruct:  XStruct{Name: "MNO", Notes: "PQR"},
+	}
+	baseXStruct := XStruct{Name: "ABC", Email: "DEF", Password: "GHI", Notes: "JKL"}
+
+	tables := []struct {
+		extractor *Extractor
+		object    interface{}
+		expected  interface{}
+	}{
+		{defaultExtractor, baseYStruct, map[string]interface{}{"Name": "ABC", "Email": "", "Notes": "PQR"}},
+		{NewExtractor(WithStrict()), baseYStruct, map[string]interface{}{"Name": "ABC", "Email": ""}},
+		{defaultExtractor, baseYStruct.Open, map[string]interface{}{"Name": "GHI"}},
+		{NewExtractor(WithStrict()), baseYStruct.Open, map[string]interface{}{"Name": "GHI"}},
+		{defaultExtractor, baseXStruct, map[string]interface{}{"Name": "ABC", "Email": "DEF", "Notes": "JKL"}},
+		{NewExtractor(WithStrict()), baseXStruct, map[string]interface{}{"Name": "ABC", "Email": "DEF"}},
+		{NewExtractor(WithStrict()), AFStruct{XStruct: baseXStruct, Title: "MNO"},
+			map[string]interface{}{"Name": "ABC", "Email": "DEF", "Title": "MNO"}},
+	}
+
+	for _, table := range tables {
+		actual, err := table.extractor.Extract(table.object, "user", ActionRead)
+		if err != nil || !reflect.DeepEqual(actual, table.expected) {
+			t.Errorf("%s (object = %+v) was incorrect, got: %+v, %v, want: %+v.", t.Name(), table.object, actual, err,
+				table.expected)
+		}
+	}
+
+	cleaned, err := NewExtractor(WithStrict()).Clean(baseXStruct, "user", ActionRead)
+	expected := &XStruct{Name: "ABC", Email: "DEF"}
+	if err != nil || !reflect.DeepEqual(cleaned, expected) {
+		t.Errorf("%s was incorrect, got: %+v, %v, want: %+v.", t.Name(), cleaned, err, expected)
+	}
+
+	cleaned, err = NewExtractor(WithStrict()).Clean(AFStruct{XStruct: baseXStruct, Title: "MNO"}, "user", ActionRead)
+	expectedAF := &AFStruct{XStruct: XStruct{Name: "ABC", Email: "DEF"}, Title: "MNO"}
+	if err != nil || !reflect.DeepEqual(cleaned, expectedAF) {
+		t.Errorf("%s was incorrect, got: %+v, %v, want: %+v.", t.Name(), cleaned, err, expectedAF)
+	}
+}
+
+// Struct with custom permission and naming tags
+type ABStruct struct {
+	Name    string    `acl:"user:r" yaml:"name" json:"-"`
+	Email   string    `acl:"admin:r" yaml:"email,omitempty"`
+	Skipped string    `acl:"user:r" yaml:"-"`
+	Price   decimal   `acl:"user:r" yaml:"price"`
+	Secret  string    `pex:"admin:r" yaml:"secret"`
+	Nested  *ABStruct `yaml:"nested,omitempty"`
+}
+
+func TestExtractorConventions(t *testing.T) {
+	t.Parallel()
+
+	extractor := NewExtractor(WithTagName("acl"), WithNamingTag("yaml"),
+		WithLeafType(reflect.TypeOf(decimal{}), func(value interface{}) (interface{}, error) {
+			return value.(decimal).units, nil
+		}))
+	baseABStruct := ABStruct{
+		Name:    "ABC",
+		Skipped: "DEF",
+		Price:   decimal{units: 1050, scale: 2},
+		Secret:  "GHI",
+		Nested:  &ABStruct{Name: "JKL", Email: "MNO"},
+	}
+
+	tables := []struct {
+		userType string
+		expected interface{}
+	}{
+		{"user", map[string]interface{}{"name": "ABC", "price": int64(1050), "secret": "GHI",
+			"nested": map[string]interface{}{"name": "JKL", "price": int64(0), "secret": ""}}},
+		{"admin", map[string]interface{}{"secret": "GHI",
+			"nested": map[string]interface{}{"email": "MNO", "secret": ""}}},
+	}
+
+	for _, table := range tables {
+		actual, err := extractor.Extract(baseABStruct, table.userType, ActionRead)
+		if err != nil || !reflect.DeepEqual(actual, table.expected) {
+			t.Errorf("%s (userType = %s) was incorrect, got: %+v, %v, want: %+v.", t.Name(), table.userType, actual,
+				err, table.expected)
+		}
+	}
+
+	if err := extractor.ValidateType(reflect.TypeOf(ABStruct{})); err != nil {
+		t.Errorf("%s was incorrect, got: %v, want: <nil>.", t.Name(), err)
+	}
+	err := NewExtractor(WithTagName("json")).ValidateType(reflect.TypeOf(ABStruct{}))
+	if !errors.Is(err, ErrMalformedTag) {
+		t.Errorf("%s was incorrect, got: %v, want: %v.", t.Name(), err, ErrMalformedTag)
+	}
+}
+
+// Struct with byte slices, slices and maps
+type AEStruct struct {
+	Data   []byte            `pex:"admin:r"`
+	Values []int             `pex:"admin:r"`
+	Labels map[string]string `pex:"admin:r"`
+}
+
+func TestExtractorOrderedOutput(t *testing.T) {
+	t.Parallel()
+
+	extractor := NewExtractor(WithOrderedOutput())
+	baseADStruct := ADStruct{
+		Name:      "ABC",
+		Addresses: []ACStruct{{Street: "DEF", Verified: true}},
+		Main:      &ACStruct{Street: "GHI"},
+		GStruct:   GStruct{Name: "JKL", Version: 1},
+	}
+
+	tables := []struct {
+		object   interface{}
+		userType string
+		expected string
+	}{
+		{AStruct{Number: 1, Text: "ABC"}, "admin", `{"Number":1,"Label":"ABC"}`},
+		{&BStruct{AStruct: AStruct{Number: 1}, Boolean: true}, "admin", `{"Number":1,"Label":"","Boolean":true}`},
+		{CStruct{Pointer: &AStruct{Number: 2}, Interface: AStruct{Text: "DEF"}}, "admin",
+			`{"Struct":{"Number":0,"Label":""},"Pointer":{"Number":2,"Label":""},` +
+				`"Interface":{"Number":0,"Label":"DEF"}}`},
+		{FStruct{Name: "ABC", Slice: []AStruct{{Number: 1}}}, "admin",
+			`{"Name":"ABC","Array":[0,0],"Slice":[{"Number":1,"Label":""}]}`},
+		{FStruct{Name: "ABC"}, "admin", `{"Name":"ABC","Array":[0,0],"Slice":null}`},
+		{AEStruct{Data: []byte("hi")}, "admin", `{"Data":"aGk=","Values":null,"Labels":null}`},
+		{AEStruct{Values: []int{}, Labels: map[string]string{}}, "admin", `{"Data":null,"Values":[],"Labels":{}}`},
+		{baseADStruct, "user", `{"name":"ABC","salary":0,"addresses":[{"street":"DEF","verified":true}],` +
+			`"contacts":null,"main":{"street":"GHI","verified":false},"Name":"JKL"}`},
+		{baseADStruct, "guest", `{"contacts":null,"main":{},"Version":1}`},
+	}
+
+	for _, table := range tables {
+		actual, err := extractor.Extract(table.object, table.userType, ActionRead)
+		encoded, _ := json.Marshal(actual)
+		if err != nil || string(encoded) != table.expected {
+			t.Errorf("%s (object = %T, userType = %s) was incorrect, got: %s, %v, want: %s.", t.Name(), table.object,
+				table.userType, encoded, err, table.expected)
+		}
+
+		// Unfiltered structs are marshaled like encoding/json does
+		if plain, _ := json.Marshal(table.object); table.userType == "admin" && string(encoded) != string(plain) {
+			t.Errorf("%s (object = %T) was incorrect, got: %s, want: %s.", t.Name(), table.object, encoded, plain)
+		}
+	}
+
+	object, _ := extractor.Extract(baseADStruct, "user", ActionRead)
+	if value, ok := object.(OrderedObject).Get("name"); !ok || value != "ABC" {
+		t.Errorf("%s (Get) was incorrect, got: %v, %t, want: ABC, true.", t.Name(), value, ok)
+	}
+}
+
+func TestExtractor(t *testing.T) {
+	t.Parallel()
+
+	extractor := NewExtractor(WithTimeLayout(time.Kitchen))
+	start := time.Date(2019, 1, 1, 10, 30, 0, 0, time.UTC)
+	baseEStruct := EStruct{Start: start}
+
+	extracted, err := extractor.Extract(&baseEStruct, "guest", ActionRead)
+	if err != nil || !reflect.DeepEqual(extracted, map[string]interface{}{}) {
+		t.Errorf("%s was incorrect, got: %+v, %v.", t.Name(), extracted, err)
+	}
+
+	cleaned, err := extractor.Clean(&baseEStruct, "admin", ActionWrite)
+	if err != nil || !reflect.DeepEqual(cleaned, &baseEStruct) {
+		t.Errorf("%s was incorrect, got: %+v, %v.", t.Name(), cleaned, err)
+	}
+
+	if err := extractor.Redact(&baseEStruct, "guest", ActionRead); err != nil || !baseEStruct.Start.IsZero() {
+		t.Errorf("%s was incorrect, got: %+v, %v.", t.Name(), baseEStruct, err)
+	}
+}
@@ -0,0 +1,91 @@
+package gopex
+
+import (
+	"fmt"
+	"reflect"
+)
+
+// Clean returns a deep copy of v where the fields the user has not access for that action are set to
+// their zero values. Unlike CleanObject the static type is kept, cleaning an *Employee returns an
+// *Employee and cleaning a []Employee returns a []Employee.
+func Clean[T any](v T, userType string, action Action) (T, error) {
+	var result T
+	cleaner := &cleaner{extractor: defaultExtractor, principal: NewPrincipal(userType), action: action,
+		copies: map[visit]reflect.Value{}}
+	copied, err := cleaner.copyValue(reflect.ValueOf(&v).Elem(), rootPath(v))
+	if err != nil {
+		return result, err
+	}
+
+	reflect.ValueOf(&result).Elem().Set(copied)
+	return result, nil
+}
+
+// Extract extracts all the fields of a struct, or a pointer to a struct, that a given user have access
+// for that action. It returns nil if v is a nil pointer.
+func Extract[T any](v T, userType string, action Action) (map[string]interface{}, error) {
+	return extractObject(defaultExtractor.newExtraction(NewPrincipal(userType), action), v, rootPath(v))
+}
+
+// ExtractSlice extracts all the fields of each struct of a slice that a given user have access for that action
+func ExtractSlice[T any](v []T, userType string, action Action) ([]map[string]interface{}, error) {
+	if v == nil {
+		return nil, nil
+	}
+
+	extraction := defaultExtractor.newExtraction(NewPrincipal(userType), action)
+	results := make([]map[string]interface{}, len(v))
+	for i, elem := range v {
+		result, err := extractObject(extraction, elem, indexPath("", i))
+		if err != nil {
+			return nil, err
+		}
+		results[i] = result
+	}
+
+	return results, nil
+}
+
+// ExtractMap extracts all the fields of each struct of a map that a given user have access for that action.
+// The keys are converted to strings like ExtractMapObjectsFields does.
+func ExtractMap[K comparable, T any](v map[K]T, userType string,
+	action Action) (map[string]map[string]interface{}, error) {
+	if v == nil {
+		return nil, nil
+	}
+
+	extraction := defaultExtractor.newExtraction(NewPrincipal(userType), action)
+	results := make(map[string]map[string]interface{}, len(v))
+	for key, elem := range v {
+		keyName, err := getMapKeyName(reflect.ValueOf(key))
+		if err != nil {
+			return nil, &Error{Path: "[" + fmt.Sprint(key) + "]", Err: err}
+		}
+
+		result, err := extractObject(extraction, elem, "["+keyName+"]")
+		if err != nil {
+			return nil, err
+		}
+		results[keyName] = result
+	}
+
+	return results, nil
+}
+
+// extractObject extracts the fields of a struct, returning an error if the object is not a struct
+func extractObject(extraction *extraction, object interface{}, path string) (map[string]interface{}, error) {
+	reflectValue := getReflectValue(object)
+	if reflectValue == nil {
+		return nil, nil
+	}
+	if reflectValue.Kind() != reflect.Struct || extraction.extractor.isLeaf(reflectValue.Type()) {
+		return nil, &Error{Path: path, Err: fmt.Errorf("%w %T, expected a struct", ErrUnsupportedKind, object)}
+	}
+
+	result, err := extraction.extractSingleObjectFields(object, path)
+	if err != nil {
+		return nil, err
+	}
+
+	return result.(map[string]interface{}), nil
+}
@@ -0,0 +1,95 @@
+package gopex
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestClean(t *testing.T) {
+	t.Parallel()
+
+	baseGStruct := GStruct{Name: "ABC", Version: 1}
+	var nilPointer *GStruct
+
+	gStruct, err := Clean(baseGStruct, "user", ActionRead)
+	if err != nil || gStruct != (GStruct{Name: "ABC"}) {
+		t.Errorf("%s was incorrect, got: %+v, %v.", t.Name(), gStruct, err)
+	}
+
+	pointer, err := Clean(&baseGStruct, "guest", ActionRead)
+	if err != nil || pointer == &baseGStruct || *pointer != (GStruct{Version: 1}) {
+		t.Errorf("%s was incorrect, got: %+v, %v.", t.Name(), pointer, err)
+	}
+
+	pointer, err = Clean(nilPointer, "guest", ActionRead)
+	if err != nil || pointer != nil {
+		t.Errorf("%s was incorrect, got: %+v, %v.", t.Name(), pointer, err)
+	}
+
+	slice, err := Clean([]GStruct{baseGStruct, baseGStruct}, "sys", ActionWrite)
+	if err != nil || !reflect.DeepEqual(slice, []GStruct{{Name: "ABC"}, {Name: "ABC"}}) {
+		t.Errorf("%s was incorrect, got: %+v, %v.", t.Name(), slice, err)
+	}
+
+	mapping, err := Clean(map[string]*GStruct{"a": &baseGStruct}, "admin", ActionRead)
+	if err != nil || !reflect.DeepEqual(mapping, map[string]*GStruct{"a": {Name: "ABC"}}) {
+		t.Errorf("%s was incorrect, got: %+v, %v.", t.Name(), mapping, err)
+	}
+
+	var empty interface{}
+	empty, err = Clean(empty, "admin", ActionRead)
+	if err != nil || empty != nil {
+		t.Errorf("%s was incorrect, got: %+v, %v.", t.Name(), empty, err)
+	}
+
+	if _, err := Clean(JStruct{}, "user", ActionRead); !errors.Is(err, ErrMalformedTag) {
+		t.Errorf("%s was incorrect, got: %v, want: %v.", t.Name(), err, ErrMalformedTag)
+	}
+}
+
+func TestExtract(t *testing.T) {
+	t.Parallel()
+
+	baseAStruct := AStruct{Number: 10, Text: "ABC"}
+	extracted := map[string]interface{}{"Number": 10, "Label": "ABC"}
+	var nilPointer *AStruct
+
+	fields, err := Extract(&baseAStruct, "user", ActionRead)
+	if err != nil || !reflect.DeepEqual(fields, extracted) {
+		t.Errorf("%s was incorrect, got: %+v, %v.", t.Name(), fields, err)
+	}
+
+	fields, err = Extract(nilPointer, "user", ActionRead)
+	if err != nil || fields != nil {
+		t.Errorf("%s was incorrect, got: %+v, %v.", t.Name(), fields, err)
+	}
+
+	slice, err := ExtractSlice([]*AStruct{&baseAStruct, nil}, "admin", ActionRead)
+	if err != nil || !reflect.DeepEqual(slice, []map[string]interface{}{extracted, nil}) {
+		t.Errorf("%s was incorrect, got: %+v, %v.", t.Name(), slice, err)
+	}
+
+	mapping, err := ExtractMap(map[int]AStruct{1: baseAStruct}, "sys", ActionWrite)
+	if err != nil || !reflect.DeepEqual(mapping, map[string]map[string]interface{}{"1": extracted}) {
+		t.Errorf("%s was incorrect, got: %+v, %v.", t.Name(), mapping, err)
+	}
+
+	tables := []struct {
+		extract     func() error
+		expectedErr error
+	}{
+		{func() error { _, err := Extract(10, "user", ActionRead); return err }, ErrUnsupportedKind},
+		{func() error { _, err := Extract(time.Now(), "user", ActionRead); return err }, ErrUnsupportedKind},
+		{func() error { _, err := Extract(map[string]int{}, "user", ActionRead); return err }, ErrUnsupportedKind},
+		{func() error { _, err := ExtractSlice([]JStruct{{}}, "user", ActionRead); return err }, ErrMalformedTag},
+		{func() error { _, err := ExtractMap(map[float64]AStruct{1: {}}, "user", ActionRead); return err }, ErrUnsupportedKind},
+	}
+
+	for i, table := range tables {
+		if err := table.extract(); !errors.Is(err, table.expectedErr) {
+			t.Errorf("%s (case = %d) was incorrect, got: %v, want: %v.", t.Name(), i, err, table.expectedErr)
+		}
+	}
+}
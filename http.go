@@ -0,0 +1,101 @@
+package gopex
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+)
+
+// RoleResolver returns the principal performing a request, or an error if it can not be resolved.
+// Errors with a StatusCode() int method are answered with that status code and their message, and the
+// others with http.StatusUnauthorized and a generic message.
+type RoleResolver func(r *http.Request) (Principal, error)
+
+// HeaderRoleResolver returns a RoleResolver that takes the roles from a comma separated header, like
+// "X-Roles: user,billing". Requests without the header are resolved to a principal without roles.
+//
+// The header is trusted as it is, so any client could claim any role by sending it. It is only safe behind
+// a trusted proxy or gateway that authenticates the requests, removes the header from incoming requests
+// and sets it itself. Otherwise resolve the roles from verified credentials, like a signed token.
+func HeaderRoleResolver(header string) RoleResolver {
+	return func(r *http.Request) (Principal, error) {
+		var roles []string
+		for _, role := range strings.Split(r.Header.Get(header), ",") {
+			if role = strings.TrimSpace(role); role != "" {
+				roles = append(roles, role)
+			}
+		}
+
+		return NewPrincipal(roles...), nil
+	}
+}
+
+// Middleware returns a middleware that resolves the principal of each request with the resolver and
+// stores it in the request context, where WriteJSON and PrincipalFromContext find it. Requests whose
+// principal can not be resolved are answered with an error instead of being handled.
+func Middleware(resolver RoleResolver) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			principal, err := resolver(r)
+			if err != nil {
+				writeError(w, err, http.StatusUnauthorized)
+				return
+			}
+
+			next.ServeHTTP(w, r.WithContext(ContextWithPrincipal(r.Context(), principal)))
+		})
+	}
+}
+
+// WriteJSON writes as JSON the fields of v the principal of the request has permission to read. The
+// principal is the one stored by Middleware, or a principal without roles if there is none.
+//
+// If the fields can not be extracted the response is an error with status code
+// http.StatusInternalServerError and a generic message, or the status code and message of the error if
+// it has a StatusCode() int method, and the error is returned.
+func WriteJSON(w http.ResponseWriter, r *http.Request, v interface{}) error {
+	return defaultExtractor.WriteJSON(w, r, v)
+}
+
+// WriteJSON writes as JSON the fields of v the principal of the request has permission to read, like
+// the WriteJSON function
+func (e *Extractor) WriteJSON(w http.ResponseWriter, r *http.Request, v interface{}) error {
+	principal, _ := PrincipalFromContext(r.Context())
+	result, err := e.ExtractFor(v, principal, ActionRead)
+	if err != nil {
+		writeError(w, err, http.StatusInternalServerError)
+		return err
+	}
+
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.WriteHeader(http.StatusOK)
+	return json.NewEncoder(w).Encode(result)
+}
+
+// writeError writes an error as a JSON response. The status code is the one of the error if it has a
+// StatusCode() int method, or the given one otherwise. A *ForbiddenError or *UnknownFieldsError is the
+// body of the response as it is, the other errors are written as {"error": "..."}. Errors without status
+// code are internal, so only the text of the status code is written.
+func writeError(w http.ResponseWriter, err error, status int) {
+	message := http.StatusText(status)
+	var statusErr interface{ StatusCode() int }
+	if errors.As(err, &statusErr) {
+		status = statusErr.StatusCode()
+		message = err.Error()
+	}
+
+	var body interface{} = map[string]string{"error": message}
+	var forbiddenErr *ForbiddenError
+	var unknownErr *UnknownFieldsError
+	if errors.As(err, &forbiddenErr) {
+		body = forbiddenErr
+	} else if errors.As(err, &unknownErr) {
+		body = unknownErr
+	}
+
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(body)
+}
@@ -0,0 +1,100 @@
+package gopex
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// statusError is an error with a status code
+type statusError int
+
+func (e statusError) Error() string {
+	return http.StatusText(int(e))
+}
+
+func (e statusError) StatusCode() int {
+	return int(e)
+}
+
+func TestHTTP(t *testing.T) {
+	t.Run("TestMiddlewareWriteJSON", testMiddlewareWriteJSON)
+	t.Run("TestMiddlewareResolverErrors", testMiddlewareResolverErrors)
+}
+
+func testMiddlewareWriteJSON(t *testing.T) {
+	t.Parallel()
+
+	var writeErr error
+	handler := Middleware(HeaderRoleResolver("X-Roles"))(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/employee":
+			_ = WriteJSON(w, r, &VStruct{Name: "ABC", Salary: 10, Notes: "DEF"})
+		case "/malformed":
+			writeErr = WriteJSON(w, r, JStruct{Version: 1})
+		}
+	}))
+
+	tables := []struct {
+		path     string
+		roles    string
+		status   int
+		expected string
+	}{
+		{"/employee", "", http.StatusOK, `{}`},
+		{"/employee", "user", http.StatusOK, `{"Name":"ABC","Notes":"DEF"}`},
+		{"/employee", " user , billing ", http.StatusOK, `{"Name":"ABC","Notes":"DEF","Salary":10}`},
+		{"/malformed", "user", http.StatusInternalServerError, `{"error":"Internal Server Error"}`},
+	}
+
+	for _, table := range tables {
+		request := httptest.NewRequest(http.MethodGet, table.path, nil)
+		if table.roles != "" {
+			request.Header.Set("X-Roles", table.roles)
+		}
+		recorder := httptest.NewRecorder()
+		handler.ServeHTTP(recorder, request)
+
+		if recorder.Code != table.status || recorder.Body.String() != table.expected+"\n" ||
+			recorder.Header().Get("Content-Type") != "application/json; charset=utf-8" {
+			t.Errorf("%s (path = %s, roles = %s) was incorrect, got: %d %s, want: %d %s.", t.Name(), table.path,
+				table.roles, recorder.Code, recorder.Body, table.status, table.expected)
+		}
+	}
+
+	if !errors.Is(writeErr, ErrMalformedTag) {
+		t.Errorf("%s (WriteJSON) was incorrect, got: %v, want: %v.", t.Name(), writeErr, ErrMalformedTag)
+	}
+}
+
+func testMiddlewareResolverErrors(t *testing.T) {
+	t.Parallel()
+
+	tables := []struct {
+		err      error
+		status   int
+		expected string
+	}{
+		{errors.New("missing token"), http.StatusUnauthorized, `{"error":"Unauthorized"}`},
+		{statusError(http.StatusForbidden), http.StatusForbidden, `{"error":"Forbidden"}`},
+		{&ForbiddenError{Violations: []Violation{{Path: "/name", Roles: []string{"user"}, Permission: "read"}}},
+			http.StatusForbidden, `{"violations":[{"path":"/name","roles":["user"],"permission":"read"}]}`},
+	}
+
+	for _, table := range tables {
+		resolver := func(r *http.Request) (Principal, error) {
+			return Principal{}, table.err
+		}
+		handler := Middleware(resolver)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			t.Errorf("%s (err = %v) was incorrect, the request was handled.", t.Name(), table.err)
+		}))
+
+		recorder := httptest.NewRecorder()
+		handler.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/", nil))
+		if recorder.Code != table.status || recorder.Body.String() != table.expected+"\n" {
+			t.Errorf("%s (err = %v) was incorrect, got: %d %s, want: %d %s.", t.Name(), table.err, recorder.Code,
+				recorder.Body, table.status, table.expected)
+		}
+	}
+}
@@ -0,0 +1,113 @@
+package gopex
+
+import (
+	"encoding"
+	"encoding/json"
+	"reflect"
+	"strings"
+	"sync"
+	"time"
+)
+
+// LeafConverter converts a value of a leaf type into the value used in the extracted object
+type LeafConverter func(value interface{}) (interface{}, error)
+
+// leafTypes are the registered leaf types and their converters
+var leafTypes sync.Map
+
+var (
+	timeType          = reflect.TypeOf(time.Time{})
+	jsonMarshalerType = reflect.TypeOf((*json.Marshaler)(nil)).Elem()
+	textMarshalerType = reflect.TypeOf((*encoding.TextMarshaler)(nil)).Elem()
+)
+
+// RegisterLeafType registers a type whose values are extracted as a whole instead of being walked
+// field by field, like time.Time. The value is converted with convert, or kept as it is if convert
+// is nil. It takes precedence over the built-in leaf types and should be called before the type is
+// used, typically in an init function.
+func RegisterLeafType(reflectType reflect.Type, convert LeafConverter) {
+	if convert == nil {
+		convert = func(value interface{}) (interface{}, error) {
+			return value, nil
+		}
+	}
+
+	leafTypes.Store(reflectType, convert)
+}
+
+// isSpecialType returns true if the values of the given type are special objects, that is leaf
+// values that are not walked. These are the registered leaf types, time.Time, the database/sql null
+// types and the types that implement json.Marshaler or encoding.TextMarshaler.
+func isSpecialType(reflectType reflect.Type) bool {
+	if _, ok := leafTypes.Load(reflectType); ok {
+		return true
+	}
+
+	return reflectType == timeType || isSQLNullType(reflectType) ||
+		implements(reflectType, jsonMarshalerType) || implements(reflectType, textMarshalerType)
+}
+
+// isLeaf returns true if the values of the given type are special objects, either leaf types of the
+// Extractor or of the package
+func (e *Extractor) isLeaf(reflectType reflect.Type) bool {
+	if _, ok := e.leafTypes[reflectType]; ok {
+		return true
+	}
+
+	return isSpecialType(reflectType)
+}
+
+// getSpecialObjectValue returns the value of a special object
+func (e *Extractor) getSpecialObjectValue(value reflect.Value, path string) (interface{}, error) {
+	if convert, ok := e.leafTypes[value.Type()]; ok {
+		result, err := convert(value.Interface())
+		if err != nil {
+			return nil, &Error{Path: path, Err: err}
+		}
+		return result, nil
+	}
+	if convert, ok := leafTypes.Load(value.Type()); ok {
+		result, err := convert.(LeafConverter)(value.Interface())
+		if err != nil {
+			return nil, &Error{Path: path, Err: err}
+		}
+		return result, nil
+	}
+
+	switch {
+	case value.Type() == timeType:
+		return e.formatTime(value.Interface().(time.Time)), nil
+	case isSQLNullType(value.Type()):
+		if !value.Field(value.NumField() - 1).Bool() {
+			return nil, nil
+		}
+		if e.isLeaf(value.Field(0).Type()) {
+			return e.getSpecialObjectValue(value.Field(0), path)
+		}
+		return value.Field(0).Interface(), nil
+	case reflect.PtrTo(value.Type()).Implements(jsonMarshalerType) && !value.Type().Implements(jsonMarshalerType),
+		reflect.PtrTo(value.Type()).Implements(textMarshalerType) && !value.Type().Implements(textMarshalerType):
+		// Keep a pointer so that encoding/json calls the methods with pointer receivers
+		if value.CanAddr() {
+			return value.Addr().Interface(), nil
+		}
+		pointer := reflect.New(value.Type())
+		pointer.Elem().Set(value)
+		return pointer.Interface(), nil
+	default:
+		return value.Interface(), nil
+	}
+}
+
+// isSQLNullType returns true if the type is one of the database/sql null types, like sql.NullString or
+// sql.Null[T], which have the value in the first field and the Valid flag in the last one
+func isSQLNullType(reflectType reflect.Type) bool {
+	return reflectType.PkgPath() == "database/sql" && strings.HasPrefix(reflectType.Name(), "Null") &&
+		reflectType.Kind() == reflect.Struct && reflectType.NumField() == 2 &&
+		reflectType.Field(1).Name == "Valid" && reflectType.Field(1).Type.Kind() == reflect.Bool
+}
+
+// implements returns true if the type or a pointer to it implements the interface
+func implements(reflectType reflect.Type, interfaceType reflect.Type) bool {
+	return reflectType.Implements(interfaceType) || reflect.PtrTo(reflectType).Implements(interfaceType)
+}
@@ -0,0 +1,154 @@
+package gopex
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// Type implementing json.Marshaler with a value receiver
+type decimal struct {
+	units int64
+	scale int
+}
+
+// MarshalJSON encodes the decimal as a JSON number
+func (d decimal) MarshalJSON() ([]byte, error) {
+	return []byte(fmt.Sprintf("%d.%0*d", d.units/100, d.scale, d.units%100)), nil
+}
+
+// Type implementing encoding.TextMarshaler with a pointer receiver
+type uuid struct {
+	High uint64 `pex:"guest:"`
+	Low  uint64 `pex:"guest:"`
+}
+
+// MarshalText encodes the UUID as hexadecimal
+func (u *uuid) MarshalText() ([]byte, error) {
+	return []byte(fmt.Sprintf("%016x%016x", u.High, u.Low)), nil
+}
+
+// Type registered as a leaf type
+type money struct {
+	Amount   int64
+	Currency string
+}
+
+// Type registered as a leaf type with a failing converter
+type broken struct {
+	Value int
+}
+
+func init() {
+	RegisterLeafType(reflect.TypeOf(money{}), func(value interface{}) (interface{}, error) {
+		m := value.(money)
+		return fmt.Sprintf("%d %s", m.Amount, m.Currency), nil
+	})
+	RegisterLeafType(reflect.TypeOf(broken{}), func(value interface{}) (interface{}, error) {
+		return nil, errors.New("broken value")
+	})
+}
+
+// Struct with leaf values
+type UStruct struct {
+	Price    decimal          `pex:"guest:r"`
+	ID       uuid             `pex:"guest:r"`
+	Total    money            `pex:"guest:r"`
+	Int32    sql.NullInt32    `pex:"guest:r"`
+	Int16    sql.NullInt16    `pex:"guest:r"`
+	Byte     sql.NullByte     `pex:"guest:r"`
+	Float    sql.NullFloat64  `pex:"guest:r"`
+	Time     sql.NullTime     `pex:"guest:r"`
+	Missing  sql.NullTime     `pex:"guest:r"`
+	Pointers []*decimal       `pex:"guest:r"`
+	Text     map[string]*uuid `pex:"guest:r"`
+}
+
+func TestLeafTypes(t *testing.T) {
+	t.Run("TestLeafTypesExtract", testLeafTypesExtract)
+	t.Run("TestLeafTypesEncoding", testLeafTypesEncoding)
+	t.Run("TestLeafTypesConverterError", testLeafTypesConverterError)
+}
+
+func testLeafTypesExtract(t *testing.T) {
+	t.Parallel()
+
+	now := time.Now()
+	baseUStruct := UStruct{
+		Price: decimal{units: 1234, scale: 2},
+		ID:    uuid{High: 1, Low: 2},
+		Total: money{Amount: 10, Currency: "EUR"},
+		Int32: sql.NullInt32{Int32: 32, Valid: true},
+		Int16: sql.NullInt16{Int16: 16, Valid: true},
+		Byte:  sql.NullByte{Byte: 8, Valid: true},
+		Float: sql.NullFloat64{Float64: 1.5},
+		Time:  sql.NullTime{Time: now, Valid: true},
+	}
+
+	actual, err := ExtractFieldsE(baseUStruct, "guest", ActionRead)
+	fields, ok := actual.(map[string]interface{})
+	if err != nil || !ok {
+		t.Fatalf("%s was incorrect, got: %+v, %v.", t.Name(), actual, err)
+	}
+
+	expected := map[string]interface{}{
+		"Price":    decimal{units: 1234, scale: 2},
+		"ID":       &uuid{High: 1, Low: 2},
+		"Total":    "10 EUR",
+		"Int32":    int32(32),
+		"Int16":    int16(16),
+		"Byte":     byte(8),
+		"Float":    nil,
+		"Time":     now.Format(time.RFC3339Nano),
+		"Missing":  nil,
+		"Pointers": []interface{}{},
+		"Text":     map[string]interface{}{},
+	}
+	if !reflect.DeepEqual(fields, expected) {
+		t.Errorf("%s was incorrect, got: %+v, want: %+v.", t.Name(), fields, expected)
+	}
+}
+
+func testLeafTypesEncoding(t *testing.T) {
+	t.Parallel()
+
+	baseUStruct := UStruct{
+		Price:    decimal{units: 1234, scale: 2},
+		ID:       uuid{High: 1, Low: 2},
+		Pointers: []*decimal{{units: 100, scale: 2}},
+	}
+
+	data, err := json.Marshal(ExtractFields(baseUStruct, "guest", ActionRead))
+	actual := string(data)
+	if err != nil || !strings.Contains(actual, `"Price":12.34`) || !strings.Contains(actual, `"Pointers":[1.00]`) ||
+		!strings.Contains(actual, `"ID":"00000000000000010000000000000002"`) {
+		t.Errorf("%s was incorrect, got: %s, %v.", t.Name(), actual, err)
+	}
+}
+
+func testLeafTypesConverterError(t *testing.T) {
+	t.Parallel()
+
+	tables := []struct {
+		object       interface{}
+		expectedPath string
+	}{
+		{broken{}, "broken"},
+		{[]interface{}{broken{}}, "[0]"},
+		{map[string]interface{}{"a": &broken{}}, "[a]"},
+	}
+
+	for _, table := range tables {
+		actual, err := ExtractFieldsE(table.object, "guest", ActionRead)
+		var pexErr *Error
+		if actual != nil || !errors.As(err, &pexErr) || pexErr.Path != table.expectedPath {
+			t.Errorf("%s (object = %+v) was incorrect, got: %+v, %v, want: %s.",
+				t.Name(), table.object, actual, err, table.expectedPath)
+		}
+	}
+}
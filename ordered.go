@@ -0,0 +1,57 @@
+package gopex
+
+import (
+	"bytes"
+	"encoding/json"
+)
+
+// OrderedField is a field of an OrderedObject
+type OrderedField struct {
+	Key   string
+	Value interface{}
+}
+
+// OrderedObject is an extracted struct that keeps its fields in declaration order, with the fields of
+// embedded structs promoted in place. It is marshaled as a JSON object with the fields in that order,
+// like encoding/json marshals the struct, instead of sorting the keys like it does with maps. With
+// ordered output nil slices and maps are kept as null and byte slices as base64 strings too, so that
+// only the leaf values converted by the Extractor, like times, differ from encoding/json.
+type OrderedObject []OrderedField
+
+// Get returns the value of a field and true, or nil and false if the object has not that field
+func (o OrderedObject) Get(key string) (interface{}, bool) {
+	for _, field := range o {
+		if field.Key == key {
+			return field.Value, true
+		}
+	}
+
+	return nil, false
+}
+
+// MarshalJSON returns the JSON encoding of the object with the fields in order
+func (o OrderedObject) MarshalJSON() ([]byte, error) {
+	var buffer bytes.Buffer
+	buffer.WriteByte('{')
+	for i, field := range o {
+		if i > 0 {
+			buffer.WriteByte(',')
+		}
+
+		key, err := json.Marshal(field.Key)
+		if err != nil {
+			return nil, err
+		}
+		value, err := json.Marshal(field.Value)
+		if err != nil {
+			return nil, err
+		}
+
+		buffer.Write(key)
+		buffer.WriteByte(':')
+		buffer.Write(value)
+	}
+	buffer.WriteByte('}')
+
+	return buffer.Bytes(), nil
+}
@@ -0,0 +1,325 @@
+package gopex
+
+import (
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// AuthorizeMergePatch checks that a user has permission to write every field a JSON Merge Patch
+// document, as defined in RFC 7396, sets or removes in a value of a type. The patch is given like the
+// payload of FilterPayload. It returns a *ForbiddenError listing every field the user has not permission
+// to write, or nil if there is none.
+func AuthorizeMergePatch(patch interface{}, reflectType reflect.Type, userType string) error {
+	return defaultExtractor.AuthorizeMergePatchFor(patch, reflectType, NewPrincipal(userType))
+}
+
+// AuthorizeMergePatchFor is like AuthorizeMergePatch but for a principal that can hold several roles
+func AuthorizeMergePatchFor(patch interface{}, reflectType reflect.Type, principal Principal) error {
+	return defaultExtractor.AuthorizeMergePatchFor(patch, reflectType, principal)
+}
+
+// AuthorizeMergePatch checks a JSON Merge Patch document, like the AuthorizeMergePatch function
+func (e *Extractor) AuthorizeMergePatch(patch interface{}, reflectType reflect.Type, userType string) error {
+	return e.AuthorizeMergePatchFor(patch, reflectType, NewPrincipal(userType))
+}
+
+// AuthorizeMergePatchFor is like AuthorizeMergePatch but for a principal that can hold several roles
+func (e *Extractor) AuthorizeMergePatchFor(patch interface{}, reflectType reflect.Type, principal Principal) error {
+	return e.ValidateWriteFor(patch, reflectType, principal)
+}
+
+// AuthorizeJSONPatch checks that a user has permission for every operation of a JSON Patch document, as
+// defined in RFC 6902, on a value of a type. The paths are resolved through the names of the fields in
+// the extracted objects, and every field a path goes through must grant the permission:
+//
+//   - add and replace need write permission on the path and on the fields of the value
+//   - remove needs write permission on the path
+//   - move needs read and write permission on from and write permission on the path
+//   - copy needs read permission on from and write permission on the path
+//   - test needs read permission on the path
+//
+// The permission is needed on the fields of the type of each path too, as the operation changes or reveals
+// all of them.
+//
+// The patch is given like the payload of FilterPayload. It returns a *ForbiddenError listing every
+// violation, an *Error wrapping ErrInvalidPatch if the patch is malformed, or nil if every operation is
+// allowed.
+func AuthorizeJSONPatch(patch interface{}, reflectType reflect.Type, userType string) error {
+	return defaultExtractor.AuthorizeJSONPatchFor(patch, reflectType, NewPrincipal(userType))
+}
+
+// AuthorizeJSONPatchFor is like AuthorizeJSONPatch but for a principal that can hold several roles
+func AuthorizeJSONPatchFor(patch interface{}, reflectType reflect.Type, principal Principal) error {
+	return defaultExtractor.AuthorizeJSONPatchFor(patch, reflectType, principal)
+}
+
+// AuthorizeJSONPatch checks a JSON Patch document, like the AuthorizeJSONPatch function
+func (e *Extractor) AuthorizeJSONPatch(patch interface{}, reflectType reflect.Type, userType string) error {
+	return e.AuthorizeJSONPatchFor(patch, reflectType, NewPrincipal(userType))
+}
+
+// AuthorizeJSONPatchFor is like AuthorizeJSONPatch but for a principal that can hold several roles
+func (e *Extractor) AuthorizeJSONPatchFor(patch interface{}, reflectType reflect.Type, principal Principal) error {
+	patch, err := decodePayload(patch)
+	if err != nil {
+		return err
+	}
+
+	operations, ok := patch.([]interface{})
+	if !ok {
+		return &Error{Err: fmt.Errorf("%w: expected an array of operations, got %T", ErrInvalidPatch, patch)}
+	}
+
+	for reflectType != nil && reflectType.Kind() == reflect.Ptr {
+		reflectType = reflectType.Elem()
+	}
+	if reflectType == nil {
+		return nil
+	}
+
+	authorizer := &patchAuthorizer{extractor: e, principal: principal, rootType: reflectType, seen: map[string]bool{}}
+	for i, operation := range operations {
+		if err := authorizer.authorizeOperation(operation, "/"+strconv.Itoa(i)); err != nil {
+			return err
+		}
+	}
+
+	if len(authorizer.violations) > 0 {
+		return &ForbiddenError{Violations: authorizer.violations}
+	}
+	return nil
+}
+
+// patchAuthorizer checks the permissions of the operations of a JSON Patch document
+type patchAuthorizer struct {
+	extractor *Extractor
+	principal Principal
+	rootType  reflect.Type
+	// violations are the violations found, without repetitions
+	violations []Violation
+	// seen are the paths and permissions of the violations found
+	seen map[string]bool
+}
+
+// authorizeOperation checks the permissions of an operation, found at the given pointer of the patch
+func (a *patchAuthorizer) authorizeOperation(operation interface{}, pointer string) error {
+	object, ok := operation.(map[string]interface{})
+	if !ok {
+		return &Error{Path: pointer, Err: fmt.Errorf("%w: expected an object, got %T", ErrInvalidPatch, operation)}
+	}
+
+	member := func(name string) (string, error) {
+		value, ok := object[name].(string)
+		if !ok {
+			return "", &Error{Path: pointer, Err: fmt.Errorf("%w: missing %q", ErrInvalidPatch, name)}
+		}
+		if value != "" && value[0] != '/' {
+			return "", &Error{Path: pointer, Err: fmt.Errorf("%w: malformed %s %q", ErrInvalidPatch, name, value)}
+		}
+		return value, nil
+	}
+
+	op, ok := object["op"].(string)
+	if !ok {
+		return &Error{Path: pointer, Err: fmt.Errorf("%w: missing %q", ErrInvalidPatch, "op")}
+	}
+	path, err := member("path")
+	if err != nil {
+		return err
+	}
+
+	switch op {
+	case "add", "replace":
+		value, ok := object["value"]
+		if !ok {
+			return &Error{Path: pointer, Err: fmt.Errorf("%w: missing %q", ErrInvalidPatch, "value")}
+		}
+		if err := a.authorizeValue(path, value); err != nil {
+			return err
+		}
+		return a.authorizeType(path, ActionWrite)
+	case "remove":
+		return a.authorizeType(path, ActionWrite)
+	case "test":
+		return a.authorizeType(path, ActionRead)
+	case "move", "copy":
+		from, err := member("from")
+		if err != nil {
+			return err
+		}
+		if err := a.authorizeType(from, ActionRead); err != nil {
+			return err
+		}
+		if op == "move" {
+			if err := a.authorizeType(from, ActionWrite); err != nil {
+				return err
+			}
+		}
+		return a.authorizeType(path, ActionWrite)
+	default:
+		return &Error{Path: pointer, Err: fmt.Errorf("%w: unknown op %q", ErrInvalidPatch, op)}
+	}
+}
+
+// authorizeValue checks the permission to write a value at a path, and its fields
+func (a *patchAuthorizer) authorizeValue(path string, value interface{}) error {
+	target, err := a.authorizePath(path, ActionWrite)
+	if err != nil || target == nil {
+		return err
+	}
+
+	filter := &payloadFilter{extractor: a.extractor, principal: a.principal, action: ActionWrite}
+	if _, err := filter.filterValue(value, target, target.Name(), path); err != nil {
+		return err
+	}
+	a.addViolations(filter.forbidden, ActionWrite)
+
+	return nil
+}
+
+// authorizeType checks the permission for an action on a path and on every field of its type, as adding,
+// replacing or removing the value at a path changes all of its fields, and testing or copying it reveals
+// them
+func (a *patchAuthorizer) authorizeType(path string, action uint) error {
+	target, err := a.authorizePath(path, action)
+	if err != nil || target == nil {
+		return err
+	}
+
+	pointers, err := a.deniedFields(target, action, target.Name(), path, false, map[reflect.Type]bool{})
+	if err != nil {
+		return err
+	}
+	a.addViolations(pointers, action)
+
+	return nil
+}
+
+// deniedFields returns the JSON pointers of the fields of a type the principal has not permission for an
+// action. The fields of the structs held in slices, arrays and maps are reported at the pointer of the
+// slice, array or map, as they stand for any of its elements. Types already visited are skipped to stop
+// recursive types.
+func (a *patchAuthorizer) deniedFields(reflectType reflect.Type, action uint, path string, pointer string,
+	elem bool, visited map[reflect.Type]bool) ([]string, error) {
+	for reflectType.Kind() == reflect.Ptr {
+		reflectType = reflectType.Elem()
+	}
+	if visited[reflectType] || a.extractor.isLeaf(reflectType) {
+		return nil, nil
+	}
+
+	switch reflectType.Kind() {
+	case reflect.Slice, reflect.Array, reflect.Map:
+		return a.deniedFields(reflectType.Elem(), action, path, pointer, true, visited)
+	case reflect.Struct:
+	default:
+		return nil, nil
+	}
+
+	visited[reflectType] = true
+	defer delete(visited, reflectType)
+
+	var pointers []string
+	for _, field := range a.extractor.plans.get(reflectType).outputs {
+		fieldPath := fieldPath(path, field.path)
+		fieldPointer := pointer
+		if !elem {
+			fieldPointer = pointerPath(pointer, field.name)
+		}
+
+		allowed, err := field.allows(a.extractor, a.principal, action)
+		if err != nil {
+			return nil, &Error{Path: fieldPath, Err: err}
+		}
+		if !allowed {
+			pointers = append(pointers, fieldPointer)
+			continue
+		}
+		if field.quoted {
+			continue
+		}
+
+		nested, err := a.deniedFields(fieldTypeByIndex(reflectType, field.index), action, fieldPath, fieldPointer,
+			elem, visited)
+		if err != nil {
+			return nil, err
+		}
+		pointers = append(pointers, nested...)
+	}
+
+	return pointers, nil
+}
+
+// authorizePath checks the permission for an action of every field a JSON pointer goes through. It returns
+// the type of the value the pointer points to, or nil if it can not be known or the permission is denied.
+func (a *patchAuthorizer) authorizePath(pointer string, action uint) (reflect.Type, error) {
+	reflectType := a.rootType
+	path := reflectType.Name()
+	current := ""
+	if pointer == "" {
+		return reflectType, nil
+	}
+
+	for _, token := range strings.Split(pointer[1:], "/") {
+		token = strings.ReplaceAll(strings.ReplaceAll(token, "~1", "/"), "~0", "~")
+		for reflectType.Kind() == reflect.Ptr {
+			reflectType = reflectType.Elem()
+		}
+		if a.extractor.isLeaf(reflectType) {
+			return nil, nil
+		}
+
+		switch reflectType.Kind() {
+		case reflect.Struct:
+			field := a.extractor.plans.get(reflectType).output(token)
+			if field == nil {
+				return nil, nil
+			}
+
+			current = pointerPath(current, token)
+			path = fieldPath(path, field.path)
+			allowed, err := field.allows(a.extractor, a.principal, action)
+			if err != nil {
+				return nil, &Error{Path: path, Err: err}
+			}
+			if !allowed {
+				a.addViolations([]string{current}, action)
+				return nil, nil
+			}
+			if field.quoted {
+				return nil, nil
+			}
+			reflectType = fieldTypeByIndex(reflectType, field.index)
+		case reflect.Slice, reflect.Array:
+			index, err := strconv.Atoi(token)
+			if token != "-" && (err != nil || index < 0) {
+				return nil, &Error{Path: path, Err: fmt.Errorf("%w: malformed index %q in %q", ErrInvalidPatch, token,
+					pointer)}
+			}
+			current = pointerPath(current, token)
+			path += "[" + token + "]"
+			reflectType = reflectType.Elem()
+		case reflect.Map:
+			current = pointerPath(current, token)
+			path += "[" + token + "]"
+			reflectType = reflectType.Elem()
+		default:
+			return nil, nil
+		}
+	}
+
+	return reflectType, nil
+}
+
+// addViolations adds the violations of the fields at the JSON pointers, skipping the repeated ones
+func (a *patchAuthorizer) addViolations(pointers []string, action uint) {
+	for _, violation := range newViolations(pointers, a.principal, action) {
+		key := violation.Permission + " " + violation.Path
+		if !a.seen[key] {
+			a.seen[key] = true
+			a.violations = append(a.violations, violation)
+		}
+	}
+}
@@ -0,0 +1,128 @@
+package gopex
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestAuthorizePatch(t *testing.T) {
+	t.Run("TestAuthorizeMergePatch", testAuthorizeMergePatch)
+	t.Run("TestAuthorizeJSONPatch", testAuthorizeJSONPatch)
+	t.Run("TestAuthorizeJSONPatchErrors", testAuthorizeJSONPatchErrors)
+}
+
+func testAuthorizeMergePatch(t *testing.T) {
+	t.Parallel()
+
+	tables := []struct {
+		patch    string
+		userType string
+		expected []string
+	}{
+		{`{"name":"ABC","main":{"street":"DEF"}}`, "user", nil},
+		{`{"name":"ABC","main":{"verified":null},"salary":null}`, "user", []string{"/main/verified", "/salary"}},
+		{`{"name":"ABC","main":null,"salary":10}`, "admin", nil},
+	}
+
+	for _, table := range tables {
+		err := AuthorizeMergePatch([]byte(table.patch), reflect.TypeOf(ADStruct{}), table.userType)
+		if !reflect.DeepEqual(violationPaths(err), table.expected) {
+			t.Errorf("%s (patch = %s, userType = %s) was incorrect, got: %v, want: %v.", t.Name(), table.patch,
+				table.userType, err, table.expected)
+		}
+	}
+}
+
+func testAuthorizeJSONPatch(t *testing.T) {
+	t.Parallel()
+
+	tables := []struct {
+		patch    string
+		userType string
+		expected []string
+	}{
+		{`[{"op":"replace","path":"/name","value":"ABC"},{"op":"add","path":"/addresses/-","value":{"street":"DEF"}}]`,
+			"user", []string{"write /addresses/-/verified"}},
+		{`[{"op":"replace","path":"/name","value":"ABC"},{"op":"add","path":"/addresses/-","value":{"street":"DEF"}}]`,
+			"admin", nil},
+		{`[{"op":"add","path":"/addresses/0","value":{"street":"DEF","verified":true}}]`, "user",
+			[]string{"write /addresses/0/verified"}},
+		{`[{"op":"remove","path":"/salary"},{"op":"replace","path":"/contacts/a~1b/verified","value":true}]`, "user",
+			[]string{"write /salary", "write /contacts/a~1b/verified"}},
+		{`[{"op":"test","path":"/Name","value":"ABC"},{"op":"copy","from":"/Version","path":"/name"}]`, "user",
+			[]string{"read /Version"}},
+		{`[{"op":"move","from":"/salary","path":"/name"},{"op":"move","from":"/salary","path":"/name"}]`, "user",
+			[]string{"write /salary"}},
+		{`[{"op":"test","path":"/Version","value":1},{"op":"copy","from":"/name","path":"/Version"}]`, "admin",
+			[]string{"read /Version", "write /Version"}},
+		{`[{"op":"replace","path":"","value":{"name":"ABC","salary":10}},{"op":"add","path":"/unknown","value":1}]`,
+			"user", []string{"write /salary", "write /addresses", "write /contacts", "write /main/verified",
+				"write /Name"}},
+		{`[{"op":"replace","path":"/main","value":{"street":"ABC"}},{"op":"remove","path":"/addresses/0"}]`, "user",
+			[]string{"write /main/verified", "write /addresses/0/verified"}},
+		{`[{"op":"test","path":"/main","value":{"verified":true}},{"op":"copy","from":"/main","path":"/Version"}]`,
+			"guest", []string{"read /main/street", "read /main/verified"}},
+		{`[{"op":"test","path":"","value":{}}]`, "user", []string{"read /Version"}},
+		{`[{"op":"copy","from":"/main","path":"/addresses/0"},{"op":"move","from":"/main","path":"/contacts/a"}]`,
+			"user", []string{"write /addresses/0/verified", "write /main/verified", "write /contacts/a/verified"}},
+		{`[{"op":"copy","from":"/main","path":""}]`, "user",
+			[]string{"write /salary", "write /addresses", "write /contacts", "write /main/verified", "write /Name"}},
+		{`[{"op":"copy","from":"/main","path":"/addresses/0"}]`, "admin", nil},
+	}
+
+	for _, table := range tables {
+		err := AuthorizeJSONPatch([]byte(table.patch), reflect.TypeOf(&ADStruct{}), table.userType)
+
+		var actual []string
+		var forbiddenErr *ForbiddenError
+		if errors.As(err, &forbiddenErr) {
+			for _, violation := range forbiddenErr.Violations {
+				actual = append(actual, violation.Permission+" "+violation.Path)
+			}
+		}
+		if forbiddenErr == nil && err != nil || !reflect.DeepEqual(actual, table.expected) {
+			t.Errorf("%s (patch = %s, userType = %s) was incorrect, got: %v, want: %v.", t.Name(), table.patch,
+				table.userType, err, table.expected)
+		}
+	}
+}
+
+func testAuthorizeJSONPatchErrors(t *testing.T) {
+	t.Parallel()
+
+	tables := []struct {
+		patch string
+	}{
+		{`{"op":"remove","path":"/name"}`},
+		{`[1]`},
+		{`[{"path":"/name"}]`},
+		{`[{"op":"remove"}]`},
+		{`[{"op":"remove","path":"name"}]`},
+		{`[{"op":"add","path":"/name"}]`},
+		{`[{"op":"copy","path":"/name"}]`},
+		{`[{"op":"remove","path":"/addresses/first"}]`},
+		{`[{"op":"delete","path":"/name"}]`},
+	}
+
+	for _, table := range tables {
+		err := AuthorizeJSONPatch([]byte(table.patch), reflect.TypeOf(ADStruct{}), "admin")
+		if !errors.Is(err, ErrInvalidPatch) {
+			t.Errorf("%s (patch = %s) was incorrect, got: %v, want: %v.", t.Name(), table.patch, err, ErrInvalidPatch)
+		}
+	}
+}
+
+// violationPaths returns the paths of the violations of a *ForbiddenError
+func violationPaths(err error) []string {
+	var forbiddenErr *ForbiddenError
+	if !errors.As(err, &forbiddenErr) {
+		return nil
+	}
+
+	paths := make([]string, len(forbiddenErr.Violations))
+	for i, violation := range forbiddenErr.Violations {
+		paths[i] = violation.Path
+	}
+	return paths
+}
@@ -1,36 +1,18 @@
 package gopex
 
 import (
-	"database/sql"
+	"encoding"
 	"encoding/json"
+	"fmt"
 	"reflect"
+	"strconv"
 	"strings"
-	"time"
 )
 
 // CleanObject is a function that receives an object, cleans it by removing the values that the user has not
 // access for that action and returns a pointer to the cleaned object
 func CleanObject(object interface{}, userType string, action uint) interface{} {
-	extractedFields := ExtractFields(object, userType, action)
-
-	// Get the reflect value
-	reflectValue := getReflectValue(object)
-	if reflectValue == nil {
-		return nil
-	}
-
-	// Create pointer to new object
-	reflectType := reflect.TypeOf(reflectValue.Interface())
-	result := reflect.New(reflectType).Interface()
-
-	// Marshal
-	data, err := json.Marshal(extractedFields)
-	if err != nil {
-		return nil
-	}
-
-	// Unmarshal
-	err = json.Unmarshal(data, &result)
+	result, err := CleanObjectE(object, userType, action)
 	if err != nil {
 		return nil
 	}
@@ -38,190 +20,247 @@ func CleanObject(object interface{}, userType string, action uint) interface{} {
 	return result
 }
 
+// CleanObjectE is like CleanObject but returns an error describing why the object could not be
+// cleaned instead of a nil result. The object is deep copied, keeping pointers, unexported fields
+// and special objects as they are, and the fields the user has not access are set to their zero values.
+func CleanObjectE(object interface{}, userType string, action uint) (interface{}, error) {
+	return defaultExtractor.Clean(object, userType, action)
+}
+
 // ExtractFields extracts all the fields that a given user have access and
 // returns a JSON interface of that object either its an array, slice or struct.
 // It uses the json tag to get the field name, it it is not defined uses the field
 // name of the struct.
 func ExtractFields(object interface{}, userType string, action uint) interface{} {
+	result, _ := defaultExtractor.extract(object, NewPrincipal(userType), action)
+	return result
+}
+
+// ExtractFieldsE is like ExtractFields but returns an error when the object has values of
+// unsupported kinds or malformed permission tags.
+func ExtractFieldsE(object interface{}, userType string, action uint) (interface{}, error) {
+	return defaultExtractor.Extract(object, userType, action)
+}
+
+// ExtractSingleObjectFields extracts all the fields that a given user have access and
+// returns a JSON interface of that object.
+// It uses the json tag to get the field name, it it is not defined uses the field
+// name of the struct.
+func ExtractSingleObjectFields(object interface{}, userType string, action uint) interface{} {
+	extraction := defaultExtractor.newExtraction(NewPrincipal(userType), action)
+	result, _ := extraction.extractSingleObjectFields(object, rootPath(object))
+	return result
+}
+
+// ExtractMultipleObjectsFields extracts all the fields that a given user have access and
+// returns a JSON interface of an array of objects.
+// It uses the json tag to get the field name of each of the objects,
+// it it is not defined uses the field name of the struct.
+func ExtractMultipleObjectsFields(object interface{}, userType string, action uint) interface{} {
+	extraction := defaultExtractor.newExtraction(NewPrincipal(userType), action)
+	result, _ := extraction.extractMultipleObjectsFields(object, rootPath(object))
+	return result
+}
+
+// ExtractMapObjectsFields extracts all the fields that a given user have access and
+// returns a JSON interface of a map of objects. The keys are converted to strings like encoding/json
+// does: strings are used as they are, encoding.TextMarshaler keys are marshaled and integers are formatted.
+// It uses the json tag to get the field name of each of the objects,
+// it it is not defined uses the field name of the struct.
+func ExtractMapObjectsFields(object interface{}, userType string, action uint) interface{} {
+	extraction := defaultExtractor.newExtraction(NewPrincipal(userType), action)
+	result, _ := extraction.extractMapObjectsFields(object, rootPath(object))
+	return result
+}
+
+// extractFields extracts the fields of any kind of object. The returned error is the first
+// one found while walking the object, the result is built regardless of it.
+func (x *extraction) extractFields(object interface{}, path string) (interface{}, error) {
 	reflectValue := getReflectValue(object)
 	if reflectValue == nil {
-		return nil
+		return nil, nil
+	}
+
+	// If special object, extract value
+	if x.extractor.isLeaf(reflectValue.Type()) {
+		return x.extractor.getSpecialObjectValue(*reflectValue, path)
 	}
 
 	switch reflectValue.Kind() {
 	case reflect.Struct:
-		return ExtractSingleObjectFields(object, userType, action)
+		return x.extractSingleObjectFields(object, path)
 	case reflect.Slice, reflect.Array:
-		return ExtractMultipleObjectsFields(object, userType, action)
+		return x.extractMultipleObjectsFields(object, path)
 	case reflect.Map:
-		return ExtractMapObjectsFields(object, userType, action)
+		return x.extractMapObjectsFields(object, path)
+	case reflect.Chan, reflect.Func, reflect.UnsafePointer, reflect.Complex64, reflect.Complex128:
+		return reflectValue.Interface(), &Error{
+			Path: path,
+			Err:  fmt.Errorf("%w %s", ErrUnsupportedKind, reflectValue.Kind()),
+		}
 	default:
-		return reflectValue.Interface()
+		return reflectValue.Interface(), nil
 	}
 }
 
-// ExtractSingleObjectFields extracts all the fields that a given user have access and
-// returns a JSON interface of that object.
-// It uses the json tag to get the field name, it it is not defined uses the field
-// name of the struct.
-func ExtractSingleObjectFields(object interface{}, userType string, action uint) interface{} {
+// extractSingleObjectFields extracts the fields of a struct
+func (x *extraction) extractSingleObjectFields(object interface{}, path string) (interface{}, error) {
 	reflectValue := getReflectValue(object)
 	if reflectValue == nil {
-		return nil
+		return nil, nil
 	}
 
 	// If not struct return the object
 	if reflectValue.Kind() != reflect.Struct {
-		return reflectValue.Interface()
+		return reflectValue.Interface(), nil
 	}
 
 	// If special object, extract value
-	if isSpecialObject(reflectValue.Interface()) {
-		return getSpecialObjectValue(reflectValue.Interface())
+	if x.extractor.isLeaf(reflectValue.Type()) {
+		return x.extractor.getSpecialObjectValue(*reflectValue, path)
 	}
 
 	// Iterate through all the fields
-	reflectType := reflect.TypeOf(reflectValue.Interface())
-	resultObject := map[string]interface{}{}
-	for i := 0; i < reflectValue.NumField(); i++ {
-		resultField := extractField(reflectType.Field(i), reflectValue.Field(i), userType, action)
-		for key, value := range resultField {
-			resultObject[key] = value
+	var firstErr error
+	outputs := x.extractor.plans.get(reflectValue.Type()).outputs
+	var resultObject map[string]interface{}
+	var orderedObject OrderedObject
+	if x.extractor.ordered {
+		orderedObject = make(OrderedObject, 0, len(outputs))
+	} else {
+		resultObject = map[string]interface{}{}
+	}
+	for _, field := range outputs {
+		value, ok := fieldByIndex(*reflectValue, field.index)
+		if !ok {
+			continue
+		}
+
+		fieldPath := fieldPath(path, field.path)
+		allowed, err := field.allows(x.extractor, x.principal, x.action)
+		if err != nil && firstErr == nil {
+			firstErr = &Error{Path: fieldPath, Err: err}
+		}
+		if !allowed || field.omitEmpty && isEmptyValue(value) {
+			continue
+		}
+
+		var result interface{}
+		if field.quoted {
+			result, err = quoteValue(value)
+			if err != nil {
+				err = &Error{Path: fieldPath, Err: err}
+			}
+		} else {
+			result, err = x.extractFields(value.Interface(), fieldPath)
+		}
+		if err != nil && firstErr == nil {
+			firstErr = err
+		}
+		if x.extractor.ordered {
+			orderedObject = append(orderedObject, OrderedField{Key: field.name, Value: result})
+		} else {
+			resultObject[field.name] = result
 		}
 	}
 
-	return resultObject
+	if x.extractor.ordered {
+		return orderedObject, firstErr
+	}
+	return resultObject, firstErr
 }
 
-// ExtractMultipleObjectsFields extracts all the fields that a given user have access and
-// returns a JSON interface of an array of objects.
-// It uses the json tag to get the field name of each of the objects,
-// it it is not defined uses the field name of the struct.
-func ExtractMultipleObjectsFields(object interface{}, userType string, action uint) interface{} {
+// extractMultipleObjectsFields extracts the fields of each element of a slice or array
+func (x *extraction) extractMultipleObjectsFields(object interface{}, path string) (interface{}, error) {
 	// Get the reflect value
 	reflectValue := getReflectValue(object)
 	if reflectValue == nil {
-		return nil
+		return nil, nil
 	}
 
 	// If not slice or array just return the object
 	if reflectValue.Kind() != reflect.Slice &&
 		reflectValue.Kind() != reflect.Array {
-		return reflectValue.Interface()
+		return reflectValue.Interface(), nil
+	}
+
+	// Ordered output is marshaled like encoding/json does, with nil slices as null and byte slices
+	// as base64 strings
+	if x.extractor.ordered && reflectValue.Kind() == reflect.Slice &&
+		(reflectValue.IsNil() || isByteSlice(reflectValue.Type())) {
+		return reflectValue.Interface(), nil
 	}
 
 	// Iterate through each single object in the slice
+	var firstErr error
 	resultObjects := make([]interface{}, reflectValue.Len())
 	for i := 0; i < reflectValue.Len(); i++ {
-		resultObjects[i] = ExtractFields(reflectValue.Index(i).Interface(), userType, action)
+		result, err := x.extractFields(reflectValue.Index(i).Interface(), indexPath(path, i))
+		if err != nil && firstErr == nil {
+			firstErr = err
+		}
+		resultObjects[i] = result
 	}
-	return resultObjects
+	return resultObjects, firstErr
 }
 
-// ExtractMapObjectsFields extracts all the fields that a given user have access and
-// returns a JSON interface of an array of objects.
-// It uses the json tag to get the field name of each of the objects,
-// it it is not defined uses the field name of the struct.
-func ExtractMapObjectsFields(object interface{}, userType string, action uint) interface{} {
+// extractMapObjectsFields extracts the fields of each value of a map
+func (x *extraction) extractMapObjectsFields(object interface{}, path string) (interface{}, error) {
 	// Get the reflect value
 	reflectValue := getReflectValue(object)
 	if reflectValue == nil {
-		return nil
+		return nil, nil
 	}
 
-	// If not slice or array just return the object
-	if reflectValue.Kind() != reflect.Map {
-		return reflectValue.Interface()
+	// If not map or, with ordered output, a nil map marshaled as null, just return the object
+	if reflectValue.Kind() != reflect.Map || x.extractor.ordered && reflectValue.IsNil() {
+		return reflectValue.Interface(), nil
 	}
 
-	// Iterate through each single object in the slice
-	resultObjects := make(map[interface{}]interface{}, reflectValue.Len())
+	// Iterate through each value in the map
+	var firstErr error
+	resultObjects := make(map[string]interface{}, reflectValue.Len())
 
 	for _, key := range reflectValue.MapKeys() {
-		resultObjects[key.Interface()] = ExtractFields(reflectValue.MapIndex(key).Interface(), userType, action)
-	}
-
-	return resultObjects
-}
-
-// extractField extracts the field and returns a map from string to interface
-func extractField(field reflect.StructField, value reflect.Value, userType string, action uint) map[string]interface{} {
-	resultField := map[string]interface{}{}
-
-	if field.PkgPath != "" { // Field is exported or not
-		return resultField
-	}
-
-	if !hasPermission(field.Tag.Get(PermissionTag), userType, action) {
-		return resultField
-	}
-
-	// Get the field name
-	fieldName := getJSONFieldName(field.Tag.Get("json"))
-	if fieldName == "" {
-		fieldName = field.Name
-	}
-
-	cleanedField := ExtractFields(value.Interface(), userType, action)
-
-	if field.Anonymous { // Anonymous fields
-		subObjectMap, ok := cleanedField.(map[string]interface{})
-		if ok {
-			for key, value := range subObjectMap {
-				resultField[key] = value
+		keyName, err := getMapKeyName(key)
+		if err != nil {
+			keyName = fmt.Sprint(key.Interface())
+			if firstErr == nil {
+				firstErr = &Error{Path: path + "[" + keyName + "]", Err: err}
 			}
+		}
 
-			return resultField
+		result, err := x.extractFields(reflectValue.MapIndex(key).Interface(), path+"["+keyName+"]")
+		if err != nil && firstErr == nil {
+			firstErr = err
 		}
+		resultObjects[keyName] = result
 	}
 
-	resultField[fieldName] = cleanedField
-	return resultField
+	return resultObjects, firstErr
 }
 
-// isSpecialObject returns true if the given object is from a certain type
-func isSpecialObject(object interface{}) bool {
-	switch object.(type) {
-	case time.Time:
-		return true
-	case sql.NullBool, sql.NullFloat64, sql.NullInt64, sql.NullString:
-		return true
-	default:
-		return false
+// getMapKeyName returns the JSON key of a map key
+func getMapKeyName(key reflect.Value) (string, error) {
+	if key.Kind() == reflect.String {
+		return key.String(), nil
 	}
-}
 
-// getSpecialObjectValue returns the value of a special object
-func getSpecialObjectValue(object interface{}) interface{} {
-	switch object.(type) {
-	case time.Time:
-		return object.(time.Time).String()
-	case sql.NullBool:
-		value := object.(sql.NullBool).Bool
-		if !object.(sql.NullBool).Valid {
-			return nil
-		}
-		return value
-	case sql.NullFloat64:
-		value := object.(sql.NullFloat64).Float64
-		if !object.(sql.NullFloat64).Valid {
-			return nil
+	if marshaler, ok := key.Interface().(encoding.TextMarshaler); ok {
+		if key.Kind() == reflect.Ptr && key.IsNil() {
+			return "", nil
 		}
-		return value
-	case sql.NullInt64:
-		value := object.(sql.NullInt64).Int64
-		if !object.(sql.NullInt64).Valid {
-			return nil
-		}
-		return value
-	case sql.NullString:
-		value := object.(sql.NullString).String
-		if !object.(sql.NullString).Valid {
-			return nil
-		}
-		return value
+		text, err := marshaler.MarshalText()
+		return string(text), err
+	}
+
+	switch key.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return strconv.FormatInt(key.Int(), 10), nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return strconv.FormatUint(key.Uint(), 10), nil
 	default:
-		return object
+		return "", fmt.Errorf("%w %s of map key", ErrUnsupportedKind, key.Kind())
 	}
 }
 
@@ -242,49 +281,142 @@ func getReflectValue(object interface{}) *reflect.Value {
 
 // getJSONFieldName returns the field name given a JSON tag
 func getJSONFieldName(jsonTag string) string {
-	if jsonTag == "" {
-		return ""
+	name, _ := parseJSONTag(jsonTag)
+	return name
+}
+
+// parseJSONTag splits a JSON tag into the field name and its options
+func parseJSONTag(jsonTag string) (string, string) {
+	if i := strings.IndexByte(jsonTag, ','); i >= 0 {
+		return jsonTag[:i], jsonTag[i+1:]
 	}
 
-	return strings.Split(jsonTag, ",")[0]
+	return jsonTag, ""
+}
+
+// hasJSONOption returns true if the options of a JSON tag contain the option
+func hasJSONOption(options string, option string) bool {
+	for options != "" {
+		var current string
+		current, options = parseJSONTag(options)
+		if current == option {
+			return true
+		}
+	}
+
+	return false
+}
+
+// isByteSlice returns true if the values of a slice type are encoded by encoding/json as base64 strings
+func isByteSlice(reflectType reflect.Type) bool {
+	elemType := reflectType.Elem()
+	return elemType.Kind() == reflect.Uint8 &&
+		!implements(elemType, jsonMarshalerType) && !implements(elemType, textMarshalerType)
+}
+
+// isQuotable returns true if the JSON string option applies to the type
+func isQuotable(reflectType reflect.Type) bool {
+	if reflectType.Name() == "" && reflectType.Kind() == reflect.Ptr {
+		reflectType = reflectType.Elem()
+	}
+
+	switch reflectType.Kind() {
+	case reflect.Bool, reflect.String,
+		reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr,
+		reflect.Float32, reflect.Float64:
+		return true
+	default:
+		return false
+	}
+}
+
+// isEmptyValue returns true if the value is empty as defined by the JSON omitempty option
+func isEmptyValue(value reflect.Value) bool {
+	switch value.Kind() {
+	case reflect.Array, reflect.Map, reflect.Slice, reflect.String:
+		return value.Len() == 0
+	case reflect.Bool:
+		return !value.Bool()
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return value.Int() == 0
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return value.Uint() == 0
+	case reflect.Float32, reflect.Float64:
+		return value.Float() == 0
+	case reflect.Interface, reflect.Ptr:
+		return value.IsNil()
+	default:
+		return false
+	}
+}
+
+// quoteValue returns the value encoded as a JSON string, as done by the JSON string option
+func quoteValue(value reflect.Value) (interface{}, error) {
+	if value.Kind() == reflect.Ptr {
+		if value.IsNil() {
+			return nil, nil
+		}
+		value = value.Elem()
+	}
+
+	data, err := json.Marshal(value.Interface())
+	if err != nil {
+		return nil, err
+	}
+
+	return string(data), nil
 }
 
 // hasPermission checks if a certain user type has permission for a given action.
-// It returns false if the permission for that user is not defined, the user does not have permission
-// for that action or the action is invalid. Returns true if the permission tag is not defined or the user
-// has permission for that action.
+// It returns false if the permission for that user is not defined nor inherited from its parent roles,
+// the user does not have permission for that action, the action is not registered or the tag is
+// malformed. Returns true if the permission tag is not defined or the user has permission for that action.
 func hasPermission(permissionTag string, userType string, action uint) bool {
+	allowed, err := checkPermission(permissionTag, userType, action)
+	return allowed && err == nil
+}
+
+// checkPermission is like hasPermission but returns a *TagError if the permission tag is malformed and
+// an error wrapping ErrUnknownAction if the action is not registered
+func checkPermission(permissionTag string, userType string, action uint) (bool, error) {
+	if err := checkAction(action); err != nil {
+		return false, err
+	}
+
 	// Get permissions tag
 	if permissionTag == "" {
-		return true
+		return true, nil
 	}
 
-	permissions := mapPermissions(permissionTag)
-
-	// Check if user type permission is defined
-	permission, ok := permissions[userType]
-	if !ok {
-		return false
+	permissions, tagErr := parsePermissionTag(permissionTag)
+	if tagErr != nil {
+		return false, tagErr
 	}
 
-	// Check permissions
-	if action == ActionRead {
-		return strings.Contains(permission, PermissionRead)
-	} else if action == ActionWrite {
-		return strings.Contains(permission, PermissionWrite)
+	return defaultExtractor.grants(permissions, NewPrincipal(userType), action), nil
+}
+
+// rootPath returns the path used in errors for the root of an object
+func rootPath(object interface{}) string {
+	reflectValue := getReflectValue(object)
+	if reflectValue == nil {
+		return ""
 	}
 
-	return false
+	return reflectValue.Type().Name()
 }
 
-// mapPermissions converts a permission tag into a map from user type to permission
-func mapPermissions(permissionTag string) map[string]string {
-	// Create permissions map
-	permissions := make(map[string]string)
-	for _, permission := range strings.Split(permissionTag, ",") {
-		pair := strings.Split(permission, ":")
-		permissions[pair[0]] = pair[1]
+// fieldPath returns the path of a field inside the value at path
+func fieldPath(path string, name string) string {
+	if path == "" {
+		return name
 	}
 
-	return permissions
+	return path + "." + name
+}
+
+// indexPath returns the path of an element inside the slice or array at path
+func indexPath(path string, index int) string {
+	return path + "[" + strconv.Itoa(index) + "]"
 }
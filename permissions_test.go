@@ -1,7 +1,10 @@
 package gopex
 
 import (
+	"encoding/json"
+	"errors"
 	"reflect"
+	"strconv"
 	"testing"
 	"time"
 	"database/sql"
@@ -327,25 +330,25 @@ func testExtractSingleObjectFieldsSpecial(t *testing.T) {
 	}{
 		// Struct
 		{baseEStruct, "guest", ActionRead, map[string]interface{}{}},
-		{baseEStruct, "user", ActionRead, map[string]interface{}{"Start": startTime.String(), "Stop": stopTime.String(), "Number": int64(10)}},
+		{baseEStruct, "user", ActionRead, map[string]interface{}{"Start": startTime.Format(time.RFC3339Nano), "Stop": stopTime.Format(time.RFC3339Nano), "Number": int64(10)}},
 		{baseEStruct, "sys", ActionRead, map[string]interface{}{}},
-		{baseEStruct, "admin", ActionRead, map[string]interface{}{"Start": startTime.String(), "Stop": stopTime.String(), "Number": int64(10)}},
+		{baseEStruct, "admin", ActionRead, map[string]interface{}{"Start": startTime.Format(time.RFC3339Nano), "Stop": stopTime.Format(time.RFC3339Nano), "Number": int64(10)}},
 
 		{baseEStruct, "guest", ActionWrite, map[string]interface{}{}},
 		{baseEStruct, "user", ActionWrite, map[string]interface{}{}},
-		{baseEStruct, "sys", ActionWrite, map[string]interface{}{"Start": startTime.String(), "Stop": stopTime.String(), "Number": int64(10)}},
-		{baseEStruct, "admin", ActionWrite, map[string]interface{}{"Start": startTime.String(), "Stop": stopTime.String(), "Number": int64(10)}},
+		{baseEStruct, "sys", ActionWrite, map[string]interface{}{"Start": startTime.Format(time.RFC3339Nano), "Stop": stopTime.Format(time.RFC3339Nano), "Number": int64(10)}},
+		{baseEStruct, "admin", ActionWrite, map[string]interface{}{"Start": startTime.Format(time.RFC3339Nano), "Stop": stopTime.Format(time.RFC3339Nano), "Number": int64(10)}},
 
 		// Pointer
 		{&baseEStruct, "guest", ActionRead, map[string]interface{}{}},
-		{&baseEStruct, "user", ActionRead, map[string]interface{}{"Start": startTime.String(), "Stop": stopTime.String(), "Number": int64(10)}},
+		{&baseEStruct, "user", ActionRead, map[string]interface{}{"Start": startTime.Format(time.RFC3339Nano), "Stop": stopTime.Format(time.RFC3339Nano), "Number": int64(10)}},
 		{&baseEStruct, "sys", ActionRead, map[string]interface{}{}},
-		{&baseEStruct, "admin", ActionRead, map[string]interface{}{"Start": startTime.String(), "Stop": stopTime.String(), "Number": int64(10)}},
+		{&baseEStruct, "admin", ActionRead, map[string]interface{}{"Start": startTime.Format(time.RFC3339Nano), "Stop": stopTime.Format(time.RFC3339Nano), "Number": int64(10)}},
 
 		{&baseEStruct, "guest", ActionWrite, map[string]interface{}{}},
 		{&baseEStruct, "user", ActionWrite, map[string]interface{}{}},
-		{&baseEStruct, "sys", ActionWrite, map[string]interface{}{"Start": startTime.String(), "Stop": stopTime.String(), "Number": int64(10)}},
-		{&baseEStruct, "admin", ActionWrite, map[string]interface{}{"Start": startTime.String(), "Stop": stopTime.String(), "Number": int64(10)}},
+		{&baseEStruct, "sys", ActionWrite, map[string]interface{}{"Start": startTime.Format(time.RFC3339Nano), "Stop": stopTime.Format(time.RFC3339Nano), "Number": int64(10)}},
+		{&baseEStruct, "admin", ActionWrite, map[string]interface{}{"Start": startTime.Format(time.RFC3339Nano), "Stop": stopTime.Format(time.RFC3339Nano), "Number": int64(10)}},
 	}
 
 	for _, table := range tables {
@@ -362,6 +365,7 @@ func TestExtractMultipleObjectsFields(t *testing.T) {
 	t.Run("TestExtractMultipleObjectsFieldsBuiltin", testExtractMultipleObjectsFieldsBuiltin)
 	t.Run("TestExtractMultipleObjectsFieldsStruct", testExtractMultipleObjectsFieldsStruct)
 	t.Run("TestExtractMapObjectFields", testExtractMapObjectFields)
+	t.Run("TestExtractMapObjectFieldsKeys", testExtractMapObjectFieldsKeys)
 }
 
 func testExtractMultipleObjectsFieldsNonSliceArray(t *testing.T) {
@@ -573,71 +577,71 @@ func testExtractMapObjectFields(t *testing.T) {
 		expected interface{}
 	}{
 		// Struct
-		{baseMap, "guest", ActionRead, map[interface{}]interface{}{
+		{baseMap, "guest", ActionRead, map[string]interface{}{
 			"foo": map[string]interface{}{},
 			"bar": map[string]interface{}{},
 		}},
-		{baseMap, "user", ActionRead, map[interface{}]interface{}{
+		{baseMap, "user", ActionRead, map[string]interface{}{
 			"foo": map[string]interface{}{"Number": 10, "Label": "ABC"},
 			"bar": map[string]interface{}{"Number": 10, "Label": "ABC"},
 		}},
-		{baseMap, "sys", ActionRead, map[interface{}]interface{}{
+		{baseMap, "sys", ActionRead, map[string]interface{}{
 			"foo": map[string]interface{}{},
 			"bar": map[string]interface{}{},
 		}},
-		{baseMap, "admin", ActionRead, map[interface{}]interface{}{
+		{baseMap, "admin", ActionRead, map[string]interface{}{
 			"foo": map[string]interface{}{"Number": 10, "Label": "ABC"},
 			"bar": map[string]interface{}{"Number": 10, "Label": "ABC"},
 		}},
 
-		{baseMap, "guest", ActionWrite, map[interface{}]interface{}{
+		{baseMap, "guest", ActionWrite, map[string]interface{}{
 			"foo": map[string]interface{}{},
 			"bar": map[string]interface{}{},
 		}},
-		{baseMap, "user", ActionWrite, map[interface{}]interface{}{
+		{baseMap, "user", ActionWrite, map[string]interface{}{
 			"foo": map[string]interface{}{},
 			"bar": map[string]interface{}{},
 		}},
-		{baseMap, "sys", ActionWrite, map[interface{}]interface{}{
+		{baseMap, "sys", ActionWrite, map[string]interface{}{
 			"foo": map[string]interface{}{"Number": 10, "Label": "ABC"},
 			"bar": map[string]interface{}{"Number": 10, "Label": "ABC"},
 		}},
-		{baseMap, "admin", ActionWrite, map[interface{}]interface{}{
+		{baseMap, "admin", ActionWrite, map[string]interface{}{
 			"foo": map[string]interface{}{"Number": 10, "Label": "ABC"},
 			"bar": map[string]interface{}{"Number": 10, "Label": "ABC"},
 		}},
 
 		// Pointer
-		{&baseMap, "guest", ActionRead, map[interface{}]interface{}{
+		{&baseMap, "guest", ActionRead, map[string]interface{}{
 			"foo": map[string]interface{}{},
 			"bar": map[string]interface{}{},
 		}},
-		{&baseMap, "user", ActionRead, map[interface{}]interface{}{
+		{&baseMap, "user", ActionRead, map[string]interface{}{
 			"foo": map[string]interface{}{"Number": 10, "Label": "ABC"},
 			"bar": map[string]interface{}{"Number": 10, "Label": "ABC"},
 		}},
-		{&baseMap, "sys", ActionRead, map[interface{}]interface{}{
+		{&baseMap, "sys", ActionRead, map[string]interface{}{
 			"foo": map[string]interface{}{},
 			"bar": map[string]interface{}{},
 		}},
-		{&baseMap, "admin", ActionRead, map[interface{}]interface{}{
+		{&baseMap, "admin", ActionRead, map[string]interface{}{
 			"foo": map[string]interface{}{"Number": 10, "Label": "ABC"},
 			"bar": map[string]interface{}{"Number": 10, "Label": "ABC"},
 		}},
 
-		{&baseMap, "guest", ActionWrite, map[interface{}]interface{}{
+		{&baseMap, "guest", ActionWrite, map[string]interface{}{
 			"foo": map[string]interface{}{},
 			"bar": map[string]interface{}{},
 		}},
-		{&baseMap, "user", ActionWrite, map[interface{}]interface{}{
+		{&baseMap, "user", ActionWrite, map[string]interface{}{
 			"foo": map[string]interface{}{},
 			"bar": map[string]interface{}{},
 		}},
-		{&baseMap, "sys", ActionWrite, map[interface{}]interface{}{
+		{&baseMap, "sys", ActionWrite, map[string]interface{}{
 			"foo": map[string]interface{}{"Number": 10, "Label": "ABC"},
 			"bar": map[string]interface{}{"Number": 10, "Label": "ABC"},
 		}},
-		{&baseMap, "admin", ActionWrite, map[interface{}]interface{}{
+		{&baseMap, "admin", ActionWrite, map[string]interface{}{
 			"foo": map[string]interface{}{"Number": 10, "Label": "ABC"},
 			"bar": map[string]interface{}{"Number": 10, "Label": "ABC"},
 		}},
@@ -652,6 +656,54 @@ func testExtractMapObjectFields(t *testing.T) {
 	}
 }
 
+// Map key implementing encoding.TextMarshaler
+type textKey struct {
+	Prefix string
+	Number int
+}
+
+// MarshalText encodes the key as the prefix followed by the number
+func (k textKey) MarshalText() ([]byte, error) {
+	return []byte(k.Prefix + "-" + strconv.Itoa(k.Number)), nil
+}
+
+func testExtractMapObjectFieldsKeys(t *testing.T) {
+	t.Parallel()
+
+	baseAStruct := AStruct{Number: 10, Text: "ABC"}
+	extracted := map[string]interface{}{"Number": 10, "Label": "ABC"}
+
+	tables := []struct {
+		object   interface{}
+		expected interface{}
+	}{
+		{map[int]AStruct{-1: baseAStruct}, map[string]interface{}{"-1": extracted}},
+		{map[uint8]*AStruct{2: &baseAStruct}, map[string]interface{}{"2": extracted}},
+		{map[textKey]interface{}{{"a", 1}: baseAStruct}, map[string]interface{}{"a-1": extracted}},
+		{map[string]map[int64]AStruct{"a": {3: baseAStruct}}, map[string]interface{}{
+			"a": map[string]interface{}{"3": extracted}}},
+		{FStruct{Name: "ABC"}, map[string]interface{}{"Name": "ABC", "Array": []interface{}{0, 0}, "Slice": []interface{}{}}},
+	}
+
+	for _, table := range tables {
+		actual, err := ExtractFieldsE(table.object, "user", ActionRead)
+		if err != nil || !reflect.DeepEqual(actual, table.expected) {
+			t.Errorf("%s (object = %+v) was incorrect, got: %+v, %v, want: %+v.", t.Name(), table.object, actual, err, table.expected)
+		}
+		if _, err := json.Marshal(actual); err != nil {
+			t.Errorf("%s (object = %+v) was incorrect, the result can not be marshaled: %v.", t.Name(), table.object, err)
+		}
+	}
+
+	unsupported := map[float64]AStruct{1.5: baseAStruct}
+	if _, err := ExtractFieldsE(unsupported, "user", ActionRead); !errors.Is(err, ErrUnsupportedKind) {
+		t.Errorf("%s (object = %+v) was incorrect, got: %v, want: %v.", t.Name(), unsupported, err, ErrUnsupportedKind)
+	}
+	if actual := ExtractFields(unsupported, "user", ActionRead); !reflect.DeepEqual(actual, map[string]interface{}{"1.5": extracted}) {
+		t.Errorf("%s (object = %+v) was incorrect, got: %+v.", t.Name(), unsupported, actual)
+	}
+}
+
 func TestExtractObjectsFeatures(t *testing.T) {
 	t.Run("TestExtractFieldsBuiltin", testExtractFieldsBuiltin)
 	t.Run("TestExtractFieldsStruct", testExtractFieldsStruct)
@@ -1125,3 +1177,113 @@ func testCleanObjectSlice(t *testing.T) {
 		}
 	}
 }
+
+// Struct with a field of an unsupported kind
+type IStruct struct {
+	Name    string   `pex:"guest:,user:r,sys:w,admin:rw"`
+	Channel chan int `pex:"guest:,user:r,sys:w,admin:rw"`
+}
+
+// Struct with a malformed permission tag
+type JStruct struct {
+	Name    string `pex:"guest:,user:r,sys:w,admin:rw"`
+	Version uint   `pex:"user,admin:rw"`
+}
+
+func TestExtractFieldsE(t *testing.T) {
+	t.Run("TestExtractFieldsEValid", testExtractFieldsEValid)
+	t.Run("TestExtractFieldsEErrors", testExtractFieldsEErrors)
+}
+
+func testExtractFieldsEValid(t *testing.T) {
+	t.Parallel()
+
+	baseAStruct := AStruct{Number: 10, Text: "ABC"}
+	var nilPointer *AStruct
+
+	tables := []struct {
+		object   interface{}
+		userType string
+		action   uint
+		expected interface{}
+	}{
+		{baseAStruct, "guest", ActionRead, map[string]interface{}{}},
+		{baseAStruct, "user", ActionRead, map[string]interface{}{"Number": 10, "Label": "ABC"}},
+		{[]AStruct{baseAStruct}, "sys", ActionWrite, []interface{}{map[string]interface{}{"Number": 10, "Label": "ABC"}}},
+		{nilPointer, "admin", ActionRead, nil},
+	}
+
+	for _, table := range tables {
+		actual, err := ExtractFieldsE(table.object, table.userType, table.action)
+		if err != nil || !reflect.DeepEqual(actual, table.expected) {
+			t.Errorf("%s (object = %+v, userType = %s, action = %d) was incorrect, got: %+v, %v, want: %+v.",
+				t.Name(), table.object, table.userType, table.action, actual, err, table.expected)
+		}
+	}
+}
+
+func testExtractFieldsEErrors(t *testing.T) {
+	t.Parallel()
+
+	baseIStruct := IStruct{Name: "ABC", Channel: make(chan int)}
+	nestedSlice := []interface{}{1, &baseIStruct}
+	baseJStruct := JStruct{Name: "ABC", Version: 1}
+
+	tables := []struct {
+		object       interface{}
+		userType     string
+		action       uint
+		expectedPath string
+		expectedErr  error
+	}{
+		{baseIStruct, "user", ActionRead, "IStruct.Channel", ErrUnsupportedKind},
+		{nestedSlice, "admin", ActionRead, "[1].Channel", ErrUnsupportedKind},
+		{[]IStruct{baseIStruct}, "sys", ActionWrite, "[0].Channel", ErrUnsupportedKind},
+		{make(chan int), "user", ActionRead, "", ErrUnsupportedKind},
+		{baseJStruct, "user", ActionRead, "JStruct.Version", ErrMalformedTag},
+		{map[string]JStruct{"a": baseJStruct}, "admin", ActionRead, "[a].Version", ErrMalformedTag},
+	}
+
+	for _, table := range tables {
+		actual, err := ExtractFieldsE(table.object, table.userType, table.action)
+		var pexErr *Error
+		if actual != nil || !errors.As(err, &pexErr) || pexErr.Path != table.expectedPath ||
+			!errors.Is(err, table.expectedErr) {
+			t.Errorf("%s (object = %+v, userType = %s, action = %d) was incorrect, got: %+v, %v, want: %s, %v.",
+				t.Name(), table.object, table.userType, table.action, actual, err, table.expectedPath, table.expectedErr)
+		}
+	}
+}
+
+func TestCleanObjectE(t *testing.T) {
+	t.Run("TestCleanObjectEErrors", testCleanObjectEErrors)
+}
+
+func testCleanObjectEErrors(t *testing.T) {
+	t.Parallel()
+
+	baseJStruct := JStruct{Name: "ABC", Version: 1}
+
+	tables := []struct {
+		object      interface{}
+		userType    string
+		action      uint
+		expectedErr error
+	}{
+		{baseJStruct, "user", ActionRead, ErrMalformedTag},
+		{&baseJStruct, "admin", ActionWrite, ErrMalformedTag},
+		{[]JStruct{baseJStruct}, "user", ActionRead, ErrMalformedTag},
+	}
+
+	for _, table := range tables {
+		actual, err := CleanObjectE(table.object, table.userType, table.action)
+		if actual != nil || !errors.Is(err, table.expectedErr) {
+			t.Errorf("%s (object = %+v, userType = %s, action = %d) was incorrect, got: %+v, %v, want: %v.",
+				t.Name(), table.object, table.userType, table.action, actual, err, table.expectedErr)
+		}
+		if CleanObject(table.object, table.userType, table.action) != nil {
+			t.Errorf("%s (object = %+v, userType = %s, action = %d) was incorrect, CleanObject did not return nil.",
+				t.Name(), table.object, table.userType, table.action)
+		}
+	}
+}
@@ -0,0 +1,320 @@
+package gopex
+
+import (
+	"reflect"
+	"sync"
+)
+
+// fieldPlan is the precomputed information of a field of a struct
+type fieldPlan struct {
+	// index is the index sequence of the field, more than one if promoted from an embedded struct
+	index []int
+	// name is the key of the field in the extracted object
+	name string
+	// path is the struct path of the field relative to its struct, like "AStruct.Number"
+	path string
+	// tags are the parsed tags of the field and of the embedded fields it is promoted through,
+	// all of them must grant the action
+	tags []fieldTag
+	// err is the error of the first malformed tag in the chain
+	err error
+	// tagged is true if the name comes from the JSON tag
+	tagged bool
+	// omitEmpty is true if the field is omitted when empty
+	omitEmpty bool
+	// quoted is true if the value is encoded as a JSON string
+	quoted bool
+}
+
+// allows returns true if the principal has permission for the action in the field
+func (f *fieldPlan) allows(e *Extractor, principal Principal, action uint) (bool, error) {
+	if f.err != nil {
+		return false, f.err
+	}
+	if err := checkAction(action); err != nil {
+		return false, err
+	}
+
+	for _, tag := range f.tags {
+		if tag.permissions == nil {
+			if !tag.embedded && (tag.annotation == AnnotationClosed || tag.annotation == "" && e.strict) {
+				return false, nil
+			}
+			continue
+		}
+
+		if !e.grants(tag.permissions, principal, action) {
+			return false, nil
+		}
+	}
+
+	return true, nil
+}
+
+// fieldTag is the parsed permission tag of a field
+type fieldTag struct {
+	// permissions is the parsed tag, nil if the field is untagged
+	permissions permissionSet
+	// annotation is the annotation of the struct the field is declared in, if any
+	annotation string
+	// embedded is true if the field is an embedded struct walked field by field, which neither strict mode
+	// nor the closed annotation hide when untagged as its fields have their own tags
+	embedded bool
+}
+
+// structPlan is the precomputed information of a struct type
+type structPlan struct {
+	// fields are the exported fields declared in the struct, in declaration order, and the ones of
+	// unexported embedded structs
+	fields []*fieldPlan
+	// outputs are the fields in the extracted object, in declaration order and with the fields
+	// of embedded structs promoted in place
+	outputs []*fieldPlan
+}
+
+// planCache is a concurrency safe cache of struct plans by type
+type planCache struct {
+	// tagName is the name of the permission tag
+	tagName string
+	// namingTag is the name of the tag with the names and options of the fields, like json
+	namingTag string
+	// isLeaf returns true if the values of a type are not walked
+	isLeaf func(reflect.Type) bool
+	plans  sync.Map
+}
+
+// newPlanCache returns an empty plan cache
+func newPlanCache(tagName string, namingTag string, isLeaf func(reflect.Type) bool) *planCache {
+	return &planCache{tagName: tagName, namingTag: namingTag, isLeaf: isLeaf}
+}
+
+// get returns the plan of a struct type, computing it if it is not cached yet
+func (c *planCache) get(reflectType reflect.Type) *structPlan {
+	if plan, ok := c.plans.Load(reflectType); ok {
+		return plan.(*structPlan)
+	}
+
+	plan := &structPlan{
+		fields:  c.compileDeclaredFields(reflectType, nil, "", nil, nil),
+		outputs: dominantFields(c.compileFields(reflectType, nil, "", nil, nil, map[reflect.Type]bool{})),
+	}
+	actual, _ := c.plans.LoadOrStore(reflectType, plan)
+	return actual.(*structPlan)
+}
+
+// compileFields computes the output fields of a struct type following the rules of encoding/json.
+// The index, path, tags and error are the ones of the embedded field the struct is promoted through,
+// if any. Embedded types are kept in visited to skip recursive embeddings, like encoding/json does.
+func (c *planCache) compileFields(reflectType reflect.Type, index []int, path string, tags []fieldTag, err error,
+	visited map[reflect.Type]bool) []*fieldPlan {
+	visited[reflectType] = true
+	defer delete(visited, reflectType)
+
+	annotation, annotationErr := c.compileAnnotation(reflectType)
+	if err == nil {
+		err = annotationErr
+	}
+
+	var fields []*fieldPlan
+	for i := 0; i < reflectType.NumField(); i++ {
+		field := reflectType.Field(i)
+		namingTag := field.Tag.Get(c.namingTag)
+		if namingTag == "-" {
+			continue
+		}
+		name, options := parseJSONTag(namingTag)
+
+		embeddedType := field.Type
+		if embeddedType.Kind() == reflect.Ptr {
+			embeddedType = embeddedType.Elem()
+		}
+		promoted := (field.Anonymous && name == "" || hasJSONOption(options, "inline")) &&
+			embeddedType.Kind() == reflect.Struct && !c.isLeaf(embeddedType)
+
+		// Unexported fields are ignored, except embedded structs whose exported fields are promoted
+		if field.PkgPath != "" && (!promoted || !field.Anonymous || field.Type.Kind() == reflect.Ptr) {
+			continue
+		}
+
+		parsed, tagErr := c.compileTag(reflectType, field)
+		fieldIndex := append(append([]int{}, index...), i)
+		fieldTags := append(append([]fieldTag{}, tags...),
+			fieldTag{permissions: parsed, annotation: annotation, embedded: promoted})
+		fieldErr := err
+		if fieldErr == nil {
+			fieldErr = tagErr
+		}
+
+		// Promote the fields of embedded structs, skipping the ones already being promoted like
+		// encoding/json does
+		if promoted {
+			if !visited[embeddedType] {
+				fields = append(fields, c.compileFields(embeddedType, fieldIndex, fieldPath(path, field.Name),
+					fieldTags, fieldErr, visited)...)
+			}
+			continue
+		}
+
+		// Get the field name
+		tagged := name != ""
+		if !tagged {
+			name = field.Name
+		}
+
+		fields = append(fields, &fieldPlan{
+			index:     fieldIndex,
+			name:      name,
+			path:      fieldPath(path, field.Name),
+			tags:      fieldTags,
+			err:       fieldErr,
+			tagged:    tagged,
+			omitEmpty: hasJSONOption(options, "omitempty"),
+			quoted:    hasJSONOption(options, "string") && isQuotable(field.Type),
+		})
+	}
+
+	return fields
+}
+
+// dominantFields removes the fields hidden by others with the same name, following the rules of
+// encoding/json: the shallowest field wins, and if there are several at the same depth the only one
+// with a name from its JSON tag wins. If there is no winner all of them are removed.
+func dominantFields(fields []*fieldPlan) []*fieldPlan {
+	byName := map[string][]*fieldPlan{}
+	for _, field := range fields {
+		byName[field.name] = append(byName[field.name], field)
+	}
+
+	var result []*fieldPlan
+	for _, field := range fields {
+		if dominantField(byName[field.name]) == field {
+			result = append(result, field)
+		}
+	}
+
+	return result
+}
+
+// dominantField returns the field that wins among fields with the same name, or nil if there is none
+func dominantField(fields []*fieldPlan) *fieldPlan {
+	var candidates []*fieldPlan
+	for _, field := range fields {
+		if len(candidates) > 0 && len(field.index) > len(candidates[0].index) {
+			continue
+		}
+		if len(candidates) > 0 && len(field.index) < len(candidates[0].index) {
+			candidates = nil
+		}
+		candidates = append(candidates, field)
+	}
+
+	if len(candidates) == 1 {
+		return candidates[0]
+	}
+
+	var tagged *fieldPlan
+	for _, candidate := range candidates {
+		if candidate.tagged {
+			if tagged != nil {
+				return nil
+			}
+			tagged = candidate
+		}
+	}
+
+	return tagged
+}
+
+// compileDeclaredFields computes the exported fields declared in a struct type. The exported fields of
+// unexported embedded structs are included as they can only be reached through them.
+func (c *planCache) compileDeclaredFields(reflectType reflect.Type, index []int, path string, tags []fieldTag,
+	err error) []*fieldPlan {
+	annotation, annotationErr := c.compileAnnotation(reflectType)
+	if err == nil {
+		err = annotationErr
+	}
+
+	var fields []*fieldPlan
+	for i := 0; i < reflectType.NumField(); i++ {
+		field := reflectType.Field(i)
+		unexportedStruct := field.PkgPath != "" && field.Anonymous &&
+			field.Type.Kind() == reflect.Struct && !c.isLeaf(field.Type)
+		if field.PkgPath != "" && !unexportedStruct {
+			continue
+		}
+
+		embeddedType := field.Type
+		if embeddedType.Kind() == reflect.Ptr {
+			embeddedType = embeddedType.Elem()
+		}
+		embedded := field.Anonymous && embeddedType.Kind() == reflect.Struct && !c.isLeaf(embeddedType)
+
+		parsed, tagErr := c.compileTag(reflectType, field)
+		fieldIndex := append(append([]int{}, index...), i)
+		fieldTags := append(append([]fieldTag{}, tags...),
+			fieldTag{permissions: parsed, annotation: annotation, embedded: embedded})
+		fieldErr := err
+		if fieldErr == nil {
+			fieldErr = tagErr
+		}
+
+		if unexportedStruct {
+			fields = append(fields, c.compileDeclaredFields(field.Type, fieldIndex, fieldPath(path, field.Name),
+				fieldTags, fieldErr)...)
+			continue
+		}
+
+		fields = append(fields, &fieldPlan{
+			index: fieldIndex,
+			name:  field.Name,
+			path:  fieldPath(path, field.Name),
+			tags:  fieldTags,
+			err:   fieldErr,
+		})
+	}
+
+	return fields
+}
+
+// compileTag parses the permission tag of a field. It returns a nil set if the field is untagged.
+func (c *planCache) compileTag(reflectType reflect.Type, field reflect.StructField) (permissionSet, error) {
+	permissionTag := field.Tag.Get(c.tagName)
+	if permissionTag == "" {
+		return nil, nil
+	}
+
+	parsed, tagErr := parsePermissionTag(permissionTag)
+	if tagErr != nil {
+		tagErr.Struct = reflectType.Name()
+		tagErr.Field = field.Name
+		return nil, tagErr
+	}
+
+	return parsed, nil
+}
+
+// compileAnnotation parses the annotation of a struct type, set as the permission tag of a blank field
+func (c *planCache) compileAnnotation(reflectType reflect.Type) (string, error) {
+	annotation, tagErr := parseAnnotation(reflectType, c.tagName)
+	if tagErr != nil {
+		return "", tagErr
+	}
+
+	return annotation, nil
+}
+
+// fieldByIndex returns the field of a struct value by its index sequence. It returns false if the
+// field is promoted through a nil embedded pointer.
+func fieldByIndex(value reflect.Value, index []int) (reflect.Value, bool) {
+	for i, x := range index {
+		if i > 0 && value.Kind() == reflect.Ptr {
+			if value.IsNil() {
+				return reflect.Value{}, false
+			}
+			value = value.Elem()
+		}
+		value = value.Field(x)
+	}
+
+	return value, true
+}
@@ -0,0 +1,380 @@
+package gopex
+
+import (
+	"encoding/json"
+	"reflect"
+	"strconv"
+	"sync"
+	"testing"
+)
+
+// Struct embedding a pointer
+type LStruct struct {
+	*AStruct `pex:"guest:,user:r,sys:w,admin:rw"`
+	Name     string `pex:"guest:r,user:r,sys:w,admin:rw"`
+}
+
+// Struct embedding itself
+type MStruct struct {
+	*MStruct
+	Name string
+}
+
+// Struct used in the benchmarks
+type NStruct struct {
+	ID        int      `pex:"guest:r,user:r,sys:w,admin:rw" json:"id"`
+	Name      string   `pex:"guest:r,user:r,sys:w,admin:rw" json:"name"`
+	Email     string   `pex:"guest:,user:r,sys:w,admin:rw" json:"email"`
+	Phone     string   `pex:"guest:,user:r,sys:w,admin:rw" json:"phone"`
+	Income    float64  `pex:"guest:,user:,sys:w,admin:rw" json:"income"`
+	Active    bool     `pex:"guest:,user:r,sys:rw,admin:rw" json:"active"`
+	Tags      []string `pex:"guest:r,user:r,sys:w,admin:rw" json:"tags"`
+	Reference *AStruct `pex:"guest:,user:r,sys:w,admin:rw" json:"reference"`
+}
+
+func TestStructPlan(t *testing.T) {
+	t.Run("TestStructPlanOutputs", testStructPlanOutputs)
+	t.Run("TestStructPlanEmbeddedPointer", testStructPlanEmbeddedPointer)
+	t.Run("TestStructPlanConcurrent", testStructPlanConcurrent)
+}
+
+func testStructPlanOutputs(t *testing.T) {
+	t.Parallel()
+
+	tables := []struct {
+		object        interface{}
+		expectedNames []string
+		expectedPaths []string
+	}{
+		{AStruct{}, []string{"Number", "Label"}, []string{"Number", "Text"}},
+		{BStruct{}, []string{"Number", "Label", "Boolean"}, []string{"AStruct.Number", "AStruct.Text", "Boolean"}},
+		{EStruct{}, []string{"Start", "Stop", "Number"}, []string{"Start", "Stop", "Number"}},
+		{LStruct{}, []string{"Number", "Label", "Name"}, []string{"AStruct.Number", "AStruct.Text", "Name"}},
+		{MStruct{}, []string{"Name"}, []string{"Name"}},
+	}
+
+	for _, table := range tables {
+		var actualNames, actualPaths []string
+		for _, field := range defaultExtractor.plans.get(reflect.TypeOf(table.object)).outputs {
+			actualNames = append(actualNames, field.name)
+			actualPaths = append(actualPaths, field.path)
+		}
+		if !reflect.DeepEqual(actualNames, table.expectedNames) || !reflect.DeepEqual(actualPaths, table.expectedPaths) {
+			t.Errorf("%s (object = %T) was incorrect, got: %v %v, want: %v %v.",
+				t.Name(), table.object, actualNames, actualPaths, table.expectedNames, table.expectedPaths)
+		}
+	}
+}
+
+func testStructPlanEmbeddedPointer(t *testing.T) {
+	t.Parallel()
+
+	baseLStruct := LStruct{AStruct: &AStruct{Number: 10, Text: "ABC"}, Name: "DEF"}
+	nilLStruct := LStruct{Name: "DEF"}
+
+	tables := []struct {
+		object   interface{}
+		userType string
+		action   uint
+		expected interface{}
+	}{
+		{baseLStruct, "guest", ActionRead, map[string]interface{}{"Name": "DEF"}},
+		{baseLStruct, "user", ActionRead, map[string]interface{}{"Number": 10, "Label": "ABC", "Name": "DEF"}},
+		{baseLStruct, "sys", ActionRead, map[string]interface{}{}},
+		{baseLStruct, "sys", ActionWrite, map[string]interface{}{"Number": 10, "Label": "ABC", "Name": "DEF"}},
+		{nilLStruct, "user", ActionRead, map[string]interface{}{"Name": "DEF"}},
+		{nilLStruct, "admin", ActionWrite, map[string]interface{}{"Name": "DEF"}},
+	}
+
+	for _, table := range tables {
+		actual := ExtractFields(table.object, table.userType, table.action)
+		if !reflect.DeepEqual(actual, table.expected) {
+			t.Errorf("%s (object = %+v, userType = %s, action = %d) was incorrect, got: %+v, want: %+v.",
+				t.Name(), table.object, table.userType, table.action, actual, table.expected)
+		}
+	}
+}
+
+func testStructPlanConcurrent(t *testing.T) {
+	t.Parallel()
+
+	cache := newPlanCache(PermissionTag, "json", isSpecialType)
+	results := make([]*structPlan, 16)
+
+	var wg sync.WaitGroup
+	for i := range results {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			results[i] = cache.get(reflect.TypeOf(NStruct{}))
+		}(i)
+	}
+	wg.Wait()
+
+	for i, result := range results {
+		if result != results[0] {
+			t.Errorf("%s (goroutine = %d) was incorrect, got a different plan.", t.Name(), i)
+		}
+	}
+}
+
+// benchmarkObjects returns a slice of structs to use in the benchmarks
+func benchmarkObjects() []NStruct {
+	objects := make([]NStruct, 1000)
+	for i := range objects {
+		objects[i] = NStruct{
+			ID:        i,
+			Name:      "Name " + strconv.Itoa(i),
+			Email:     "name@example.com",
+			Phone:     "+351 910 000 000",
+			Income:    1000.0,
+			Active:    true,
+			Tags:      []string{"a", "b"},
+			Reference: &AStruct{Number: i, Text: "ABC"},
+		}
+	}
+
+	return objects
+}
+
+// extractFieldsParsingTags extracts the fields as before the plans were cached, looking up and
+// parsing the permission tag of every field of every object.
+func extractFieldsParsingTags(object interface{}, userType string, action uint) interface{} {
+	reflectValue := getReflectValue(object)
+	if reflectValue == nil {
+		return nil
+	}
+
+	switch reflectValue.Kind() {
+	case reflect.Struct:
+		reflectType := reflectValue.Type()
+		resultObject := map[string]interface{}{}
+		for i := 0; i < reflectValue.NumField(); i++ {
+			field := reflectType.Field(i)
+			if field.PkgPath != "" || !hasPermission(field.Tag.Get(PermissionTag), userType, action) {
+				continue
+			}
+
+			fieldName := getJSONFieldName(field.Tag.Get("json"))
+			if fieldName == "" {
+				fieldName = field.Name
+			}
+			resultObject[fieldName] = extractFieldsParsingTags(reflectValue.Field(i).Interface(), userType, action)
+		}
+		return resultObject
+	case reflect.Slice, reflect.Array:
+		resultObjects := make([]interface{}, reflectValue.Len())
+		for i := 0; i < reflectValue.Len(); i++ {
+			resultObjects[i] = extractFieldsParsingTags(reflectValue.Index(i).Interface(), userType, action)
+		}
+		return resultObjects
+	default:
+		return reflectValue.Interface()
+	}
+}
+
+func BenchmarkExtractMultipleObjectsFields(b *testing.B) {
+	objects := benchmarkObjects()
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		ExtractMultipleObjectsFields(objects, "user", ActionRead)
+	}
+}
+
+func BenchmarkExtractMultipleObjectsFieldsParsingTags(b *testing.B) {
+	objects := benchmarkObjects()
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		extractFieldsParsingTags(objects, "user", ActionRead)
+	}
+}
+
+func BenchmarkCleanObject(b *testing.B) {
+	objects := benchmarkObjects()
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		CleanObject(objects, "user", ActionRead)
+	}
+}
+
+// Struct embedded without being exported
+type embeddedStruct struct {
+	Embedded string `pex:"guest:,user:r,sys:w,admin:rw"`
+	Name     string `pex:"guest:,user:r,sys:w,admin:rw"`
+}
+
+// Struct with JSON tag options
+type QStruct struct {
+	embeddedStruct
+	AStruct  `json:"inner"`
+	Name     string  `pex:"guest:,user:r,sys:w,admin:rw" json:"name"`
+	Ignored  string  `pex:"guest:,user:r,sys:w,admin:rw" json:"-"`
+	Dash     string  `pex:"guest:,user:r,sys:w,admin:rw" json:"-,"`
+	Empty    *int    `pex:"guest:,user:r,sys:w,admin:rw" json:",omitempty"`
+	Count    int64   `pex:"guest:,user:r,sys:w,admin:rw" json:"count,string"`
+	Ratio    float64 `pex:"guest:,user:r,sys:w,admin:rw" json:"ratio,omitempty,string"`
+	Quoted   string  `pex:"guest:,user:r,sys:w,admin:rw" json:"quoted,string"`
+	Inline   AStruct `pex:"guest:,user:r,sys:w,admin:rw" json:",inline"`
+	unlisted string
+}
+
+// Structs with conflicting field names
+type RStruct struct {
+	Number int    `pex:"guest:,user:r,sys:w,admin:rw"`
+	Label  string `pex:"guest:,user:r,sys:w,admin:rw"`
+}
+
+type SStruct struct {
+	AStruct
+	RStruct
+}
+
+type TStruct struct {
+	AStruct
+	Label string `pex:"guest:,user:r,sys:w,admin:rw"`
+}
+
+func TestJSONTagOptions(t *testing.T) {
+	t.Run("TestJSONTagOptionsFields", testJSONTagOptionsFields)
+	t.Run("TestJSONTagOptionsConflicts", testJSONTagOptionsConflicts)
+	t.Run("TestJSONTagOptionsEncoding", testJSONTagOptionsEncoding)
+	t.Run("TestJSONTagOptionsUnexportedEmbedded", testJSONTagOptionsUnexportedEmbedded)
+}
+
+func testJSONTagOptionsFields(t *testing.T) {
+	t.Parallel()
+
+	baseQStruct := QStruct{
+		embeddedStruct: embeddedStruct{Embedded: "ABC", Name: "hidden"},
+		AStruct:        AStruct{Number: 10, Text: "DEF"},
+		Name:           "GHI",
+		Ignored:        "JKL",
+		Dash:           "MNO",
+		Count:          20,
+		Quoted:         "PQR",
+		Inline:         AStruct{Number: 30, Text: "STU"},
+	}
+
+	tables := []struct {
+		object   interface{}
+		userType string
+		action   uint
+		expected interface{}
+	}{
+		{baseQStruct, "guest", ActionRead, map[string]interface{}{"inner": map[string]interface{}{}}},
+		{baseQStruct, "user", ActionRead, map[string]interface{}{
+			"Embedded": "ABC",
+			"Name":     "hidden",
+			"inner":    map[string]interface{}{"Number": 10, "Label": "DEF"},
+			"name":     "GHI",
+			"-":        "MNO",
+			"count":    "20",
+			"quoted":   `"PQR"`,
+			"Number":   30,
+			"Label":    "STU",
+		}},
+		{baseQStruct, "sys", ActionRead, map[string]interface{}{"inner": map[string]interface{}{}}},
+		{baseQStruct, "sys", ActionWrite, map[string]interface{}{
+			"Embedded": "ABC",
+			"Name":     "hidden",
+			"inner":    map[string]interface{}{"Number": 10, "Label": "DEF"},
+			"name":     "GHI",
+			"-":        "MNO",
+			"count":    "20",
+			"quoted":   `"PQR"`,
+			"Number":   30,
+			"Label":    "STU",
+		}},
+	}
+
+	for _, table := range tables {
+		actual := ExtractFields(table.object, table.userType, table.action)
+		if !reflect.DeepEqual(actual, table.expected) {
+			t.Errorf("%s (object = %+v, userType = %s, action = %d) was incorrect, got: %+v, want: %+v.",
+				t.Name(), table.object, table.userType, table.action, actual, table.expected)
+		}
+	}
+}
+
+func testJSONTagOptionsConflicts(t *testing.T) {
+	t.Parallel()
+
+	baseSStruct := SStruct{AStruct: AStruct{Number: 10, Text: "ABC"}, RStruct: RStruct{Number: 20, Label: "DEF"}}
+	baseTStruct := TStruct{AStruct: AStruct{Number: 10, Text: "ABC"}, Label: "DEF"}
+
+	tables := []struct {
+		object   interface{}
+		userType string
+		action   uint
+		expected interface{}
+	}{
+		{baseSStruct, "user", ActionRead, map[string]interface{}{"Label": "ABC"}},
+		{baseTStruct, "user", ActionRead, map[string]interface{}{"Number": 10, "Label": "DEF"}},
+	}
+
+	for _, table := range tables {
+		actual := ExtractFields(table.object, table.userType, table.action)
+		if !reflect.DeepEqual(actual, table.expected) {
+			t.Errorf("%s (object = %+v, userType = %s, action = %d) was incorrect, got: %+v, want: %+v.",
+				t.Name(), table.object, table.userType, table.action, actual, table.expected)
+		}
+	}
+}
+
+func testJSONTagOptionsEncoding(t *testing.T) {
+	t.Parallel()
+
+	number := 5
+	tables := []struct {
+		object interface{}
+		// inlined are the keys that only exist because of the inline option
+		inlined []string
+	}{
+		{SStruct{AStruct: AStruct{Number: 10, Text: "ABC"}, RStruct: RStruct{Number: 20, Label: "DEF"}}, nil},
+		{TStruct{AStruct: AStruct{Number: 10, Text: "ABC"}, Label: "DEF"}, nil},
+		{QStruct{embeddedStruct: embeddedStruct{Embedded: "ABC"}, Name: "GHI", Count: 20, Ratio: 0.5, Empty: &number},
+			[]string{"Inline", "Number", "Label"}},
+	}
+
+	for _, table := range tables {
+		expected, _ := json.Marshal(table.object)
+		var expectedObject map[string]interface{}
+		_ = json.Unmarshal(expected, &expectedObject)
+
+		data, err := json.Marshal(ExtractFields(table.object, "admin", ActionRead))
+		var actualObject map[string]interface{}
+		_ = json.Unmarshal(data, &actualObject)
+
+		for _, key := range table.inlined {
+			delete(expectedObject, key)
+			delete(actualObject, key)
+		}
+
+		if err != nil || !reflect.DeepEqual(actualObject, expectedObject) {
+			t.Errorf("%s (object = %+v) was incorrect, got: %s, %v, want: %s.", t.Name(), table.object, data, err, expected)
+		}
+	}
+}
+
+func testJSONTagOptionsUnexportedEmbedded(t *testing.T) {
+	t.Parallel()
+
+	baseQStruct := QStruct{embeddedStruct: embeddedStruct{Embedded: "ABC", Name: "DEF"}, unlisted: "GHI"}
+
+	cleaned := CleanObject(baseQStruct, "guest", ActionRead).(*QStruct)
+	if cleaned.Embedded != "" || cleaned.embeddedStruct.Name != "" || cleaned.unlisted != "GHI" {
+		t.Errorf("%s was incorrect, got: %+v.", t.Name(), cleaned)
+	}
+
+	redacted := baseQStruct
+	if err := Redact(&redacted, "guest", ActionRead); err != nil || redacted.Embedded != "" ||
+		redacted.embeddedStruct.Name != "" || redacted.unlisted != "GHI" {
+		t.Errorf("%s was incorrect, got: %+v, %v.", t.Name(), redacted, err)
+	}
+}
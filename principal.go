@@ -0,0 +1,119 @@
+package gopex
+
+import (
+	"context"
+	"fmt"
+)
+
+// Principal is whoever performs an action on an object. It holds a set of roles, the user types of the
+// permission tags, and has permission for an action in a field if any of its roles has. The ID and the
+// tenant are not used to check permissions, they are carried for the code that needs them.
+type Principal struct {
+	Roles  []string
+	ID     string
+	Tenant string
+}
+
+// NewPrincipal returns a principal with the given roles
+func NewPrincipal(roles ...string) Principal {
+	return Principal{Roles: roles}
+}
+
+// HasRole returns true if the principal has the role
+func (p Principal) HasRole(role string) bool {
+	for _, current := range p.Roles {
+		if current == role {
+			return true
+		}
+	}
+
+	return false
+}
+
+// principalKey is the context key of the principal
+type principalKey struct{}
+
+// ContextWithPrincipal returns a copy of the context carrying the principal
+func ContextWithPrincipal(ctx context.Context, principal Principal) context.Context {
+	return context.WithValue(ctx, principalKey{}, principal)
+}
+
+// PrincipalFromContext returns the principal carried by the context, or false if there is none
+func PrincipalFromContext(ctx context.Context) (Principal, bool) {
+	principal, ok := ctx.Value(principalKey{}).(Principal)
+	return principal, ok
+}
+
+// principalFromContext returns the principal carried by the context, or an *Error wrapping
+// ErrNoPrincipal if there is none
+func principalFromContext(ctx context.Context) (Principal, error) {
+	principal, ok := PrincipalFromContext(ctx)
+	if !ok {
+		return Principal{}, &Error{Err: fmt.Errorf("%w in context", ErrNoPrincipal)}
+	}
+
+	return principal, nil
+}
+
+// ExtractFieldsContext is like ExtractFieldsFor but for the principal carried by the context, see
+// ContextWithPrincipal. It returns an error wrapping ErrNoPrincipal if there is none.
+func ExtractFieldsContext(ctx context.Context, object interface{}, action uint) (interface{}, error) {
+	return defaultExtractor.ExtractContext(ctx, object, action)
+}
+
+// CleanObjectContext is like CleanObjectFor but for the principal carried by the context, see
+// ContextWithPrincipal. It returns an error wrapping ErrNoPrincipal if there is none.
+func CleanObjectContext(ctx context.Context, object interface{}, action uint) (interface{}, error) {
+	return defaultExtractor.CleanContext(ctx, object, action)
+}
+
+// RedactContext is like RedactFor but for the principal carried by the context, see
+// ContextWithPrincipal. It returns an error wrapping ErrNoPrincipal if there is none.
+func RedactContext(ctx context.Context, ptr interface{}, action uint) error {
+	return defaultExtractor.RedactContext(ctx, ptr, action)
+}
+
+// ExtractFieldsFor is like ExtractFieldsE but for a principal that can hold several roles
+func ExtractFieldsFor(object interface{}, principal Principal, action uint) (interface{}, error) {
+	return defaultExtractor.ExtractFor(object, principal, action)
+}
+
+// CleanObjectFor is like CleanObjectE but for a principal that can hold several roles
+func CleanObjectFor(object interface{}, principal Principal, action uint) (interface{}, error) {
+	return defaultExtractor.CleanFor(object, principal, action)
+}
+
+// RedactFor is like Redact but for a principal that can hold several roles
+func RedactFor(ptr interface{}, principal Principal, action uint) error {
+	return defaultExtractor.RedactFor(ptr, principal, action)
+}
+
+// ExtractContext is like ExtractFor but for the principal carried by the context
+func (e *Extractor) ExtractContext(ctx context.Context, object interface{}, action uint) (interface{}, error) {
+	principal, err := principalFromContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	return e.ExtractFor(object, principal, action)
+}
+
+// CleanContext is like CleanFor but for the principal carried by the context
+func (e *Extractor) CleanContext(ctx context.Context, object interface{}, action uint) (interface{}, error) {
+	principal, err := principalFromContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	return e.CleanFor(object, principal, action)
+}
+
+// RedactContext is like RedactFor but for the principal carried by the context
+func (e *Extractor) RedactContext(ctx context.Context, ptr interface{}, action uint) error {
+	principal, err := principalFromContext(ctx)
+	if err != nil {
+		return err
+	}
+
+	return e.RedactFor(ptr, principal, action)
+}
@@ -0,0 +1,151 @@
+package gopex
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+)
+
+// Struct with permissions split across roles
+type VStruct struct {
+	Name    string `pex:"user:r,billing:r"`
+	Salary  uint   `pex:"billing:rw"`
+	Notes   string `pex:"user:rw"`
+	GStruct `pex:"user:r"`
+}
+
+func TestPrincipal(t *testing.T) {
+	t.Run("TestPrincipalHasRole", testPrincipalHasRole)
+	t.Run("TestExtractFieldsFor", testExtractFieldsFor)
+	t.Run("TestCleanObjectFor", testCleanObjectFor)
+	t.Run("TestRedactFor", testRedactFor)
+	t.Run("TestPrincipalContext", testPrincipalContext)
+}
+
+func testPrincipalHasRole(t *testing.T) {
+	t.Parallel()
+
+	principal := NewPrincipal("user", "billing")
+	tables := []struct {
+		role     string
+		expected bool
+	}{
+		{"user", true},
+		{"billing", true},
+		{"admin", false},
+		{"", false},
+	}
+
+	for _, table := range tables {
+		result := principal.HasRole(table.role)
+		if result != table.expected {
+			t.Errorf("%s (role = %s) was incorrect, got: %t, want: %t.", t.Name(), table.role, result, table.expected)
+		}
+	}
+}
+
+func testExtractFieldsFor(t *testing.T) {
+	t.Parallel()
+
+	object := VStruct{Name: "ABC", Salary: 1, Notes: "DEF", GStruct: GStruct{Name: "GHI", Version: 2}}
+	tables := []struct {
+		principal Principal
+		action    uint
+		expected  interface{}
+	}{
+		{NewPrincipal(), ActionRead, map[string]interface{}{}},
+		{NewPrincipal("user"), ActionRead, map[string]interface{}{"Name": "ABC", "Notes": "DEF"}},
+		{NewPrincipal("billing"), ActionRead, map[string]interface{}{"Name": "ABC", "Salary": uint(1)}},
+		{NewPrincipal("user", "billing"), ActionRead,
+			map[string]interface{}{"Name": "ABC", "Salary": uint(1), "Notes": "DEF"}},
+		{NewPrincipal("user", "billing"), ActionWrite, map[string]interface{}{"Salary": uint(1), "Notes": "DEF"}},
+		{NewPrincipal("user", "sys"), ActionRead,
+			map[string]interface{}{"Name": "ABC", "Notes": "DEF", "Version": uint(2)}},
+	}
+
+	for _, table := range tables {
+		result, err := ExtractFieldsFor(object, table.principal, table.action)
+		if err != nil || !reflect.DeepEqual(result, table.expected) {
+			t.Errorf("%s (principal = %+v, action = %d) was incorrect, got: %+v (%v), want: %+v.", t.Name(),
+				table.principal, table.action, result, err, table.expected)
+		}
+	}
+}
+
+func testCleanObjectFor(t *testing.T) {
+	t.Parallel()
+
+	object := VStruct{Name: "ABC", Salary: 1, Notes: "DEF", GStruct: GStruct{Name: "GHI", Version: 2}}
+	tables := []struct {
+		principal Principal
+		action    uint
+		expected  interface{}
+	}{
+		{NewPrincipal("user"), ActionRead, &VStruct{Name: "ABC", Notes: "DEF", GStruct: GStruct{Name: "GHI"}}},
+		{NewPrincipal("user", "billing"), ActionWrite, &VStruct{Salary: 1, Notes: "DEF"}},
+		{NewPrincipal("user", "sys"), ActionRead, &VStruct{Name: "ABC", Notes: "DEF",
+			GStruct: GStruct{Name: "GHI", Version: 2}}},
+	}
+
+	for _, table := range tables {
+		result, err := CleanObjectFor(object, table.principal, table.action)
+		if err != nil || !reflect.DeepEqual(result, table.expected) {
+			t.Errorf("%s (principal = %+v, action = %d) was incorrect, got: %+v (%v), want: %+v.", t.Name(),
+				table.principal, table.action, result, err, table.expected)
+		}
+	}
+}
+
+func testRedactFor(t *testing.T) {
+	t.Parallel()
+
+	object := &VStruct{Name: "ABC", Salary: 1, Notes: "DEF", GStruct: GStruct{Name: "GHI", Version: 2}}
+	principal := NewPrincipal("billing", "sys")
+	expected := &VStruct{Name: "ABC", Salary: 1}
+
+	if err := RedactFor(object, principal, ActionRead); err != nil || !reflect.DeepEqual(object, expected) {
+		t.Errorf("%s (principal = %+v, action = %d) was incorrect, got: %+v (%v), want: %+v.", t.Name(),
+			principal, ActionRead, object, err, expected)
+	}
+}
+
+func testPrincipalContext(t *testing.T) {
+	t.Parallel()
+
+	principal := Principal{Roles: []string{"user", "billing"}, ID: "42", Tenant: "acme"}
+	ctx := ContextWithPrincipal(context.Background(), principal)
+	if actual, ok := PrincipalFromContext(ctx); !ok || !reflect.DeepEqual(actual, principal) {
+		t.Errorf("%s was incorrect, got: %+v, %t, want: %+v.", t.Name(), actual, ok, principal)
+	}
+
+	object := VStruct{Name: "ABC", Salary: 1, Notes: "DEF"}
+	extracted, err := ExtractFieldsContext(ctx, object, ActionRead)
+	expected := map[string]interface{}{"Name": "ABC", "Salary": uint(1), "Notes": "DEF"}
+	if err != nil || !reflect.DeepEqual(extracted, expected) {
+		t.Errorf("%s was incorrect, got: %+v, %v, want: %+v.", t.Name(), extracted, err, expected)
+	}
+
+	cleaned, err := CleanObjectContext(ctx, object, ActionWrite)
+	if err != nil || !reflect.DeepEqual(cleaned, &VStruct{Salary: 1, Notes: "DEF"}) {
+		t.Errorf("%s was incorrect, got: %+v, %v, want: %+v.", t.Name(), cleaned, err, &VStruct{Salary: 1, Notes: "DEF"})
+	}
+
+	if err := RedactContext(ctx, &object, ActionWrite); err != nil || object.Name != "" {
+		t.Errorf("%s was incorrect, got: %+v, %v.", t.Name(), object, err)
+	}
+
+	if _, ok := PrincipalFromContext(context.Background()); ok {
+		t.Errorf("%s was incorrect, got a principal from an empty context.", t.Name())
+	}
+	if extracted, err := ExtractFieldsContext(context.Background(), object, ActionRead); extracted != nil ||
+		!errors.Is(err, ErrNoPrincipal) {
+		t.Errorf("%s was incorrect, got: %+v, %v, want: %v.", t.Name(), extracted, err, ErrNoPrincipal)
+	}
+	if _, err := CleanObjectContext(context.Background(), object, ActionRead); !errors.Is(err, ErrNoPrincipal) {
+		t.Errorf("%s was incorrect, got: %v, want: %v.", t.Name(), err, ErrNoPrincipal)
+	}
+	if err := RedactContext(context.Background(), &object, ActionRead); !errors.Is(err, ErrNoPrincipal) {
+		t.Errorf("%s was incorrect, got: %v, want: %v.", t.Name(), err, ErrNoPrincipal)
+	}
+}
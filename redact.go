@@ -0,0 +1,143 @@
+package gopex
+
+import (
+	"fmt"
+	"reflect"
+)
+
+// Redact sets every field the user has not access for that action to its zero value, modifying the
+// object pointed by ptr in place. It walks through pointers, interfaces, slices, arrays, maps and embedded
+// structs, visiting each pointer, map and slice only once so that self referencing objects are supported.
+func Redact(ptr interface{}, userType string, action uint) error {
+	return defaultExtractor.Redact(ptr, userType, action)
+}
+
+// redactor zeroes in place the fields a user has not access
+type redactor struct {
+	extractor *Extractor
+	principal Principal
+	action    uint
+	// visited are the pointers, maps and slices already redacted
+	visited map[visit]bool
+}
+
+// redactValue redacts a value. Structs and arrays are only redacted if the value is addressable.
+func (r *redactor) redactValue(value reflect.Value, path string) error {
+	if value.Kind() != reflect.Ptr && value.Kind() != reflect.Interface && r.extractor.isLeaf(value.Type()) {
+		return nil
+	}
+
+	switch value.Kind() {
+	case reflect.Ptr:
+		if value.IsNil() || r.visit(value, 0) {
+			return nil
+		}
+		return r.redactValue(value.Elem(), path)
+	case reflect.Interface:
+		if value.IsNil() {
+			return nil
+		}
+		return r.redactElem(value.Elem(), path, value.Set)
+	case reflect.Struct:
+		return r.redactStruct(value, path)
+	case reflect.Slice:
+		if value.IsNil() || r.visit(value, value.Len()) {
+			return nil
+		}
+		return r.redactElements(value, path)
+	case reflect.Array:
+		return r.redactElements(value, path)
+	case reflect.Map:
+		if value.IsNil() || r.visit(value, 0) {
+			return nil
+		}
+
+		for _, key := range value.MapKeys() {
+			keyPath := path + "[" + fmt.Sprint(key.Interface()) + "]"
+			err := r.redactElem(value.MapIndex(key), keyPath, func(elem reflect.Value) {
+				value.SetMapIndex(key, elem)
+			})
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	default:
+		return nil
+	}
+}
+
+// redactElem redacts a value that is not addressable, like the value of an interface or a map.
+// Structs and arrays are redacted in a copy which is then stored with set.
+func (r *redactor) redactElem(elem reflect.Value, path string, set func(reflect.Value)) error {
+	// Interfaces held in maps are not addressable either, so their values are stored with set too
+	if elem.Kind() == reflect.Interface {
+		if elem.IsNil() {
+			return nil
+		}
+		return r.redactElem(elem.Elem(), path, set)
+	}
+
+	if elem.Kind() != reflect.Struct && elem.Kind() != reflect.Array {
+		return r.redactValue(elem, path)
+	}
+
+	copied := reflect.New(elem.Type()).Elem()
+	copied.Set(elem)
+	if err := r.redactValue(copied, path); err != nil {
+		return err
+	}
+
+	set(copied)
+	return nil
+}
+
+// redactStruct sets the fields of a struct the user has not access to their zero values and
+// redacts the others
+func (r *redactor) redactStruct(value reflect.Value, path string) error {
+	if !value.CanAddr() {
+		return nil
+	}
+
+	for _, field := range r.extractor.plans.get(value.Type()).fields {
+		fieldPath := fieldPath(path, field.path)
+		allowed, err := field.allows(r.extractor, r.principal, r.action)
+		if err != nil {
+			return &Error{Path: fieldPath, Err: err}
+		}
+
+		fieldValue := value.FieldByIndex(field.index)
+		if !allowed {
+			fieldValue.Set(reflect.Zero(fieldValue.Type()))
+			continue
+		}
+
+		if err := r.redactValue(fieldValue, fieldPath); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// redactElements redacts each element of a slice or array
+func (r *redactor) redactElements(value reflect.Value, path string) error {
+	for i := 0; i < value.Len(); i++ {
+		if err := r.redactValue(value.Index(i), indexPath(path, i)); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// visit marks a pointer, map or slice as visited, returning true if it was already visited
+func (r *redactor) visit(value reflect.Value, length int) bool {
+	key := visit{pointer: value.Pointer(), length: length, reflectType: value.Type()}
+	if r.visited[key] {
+		return true
+	}
+
+	r.visited[key] = true
+	return false
+}
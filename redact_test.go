@@ -0,0 +1,154 @@
+package gopex
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+// Struct with every kind of container
+type PStruct struct {
+	Name     string `pex:"guest:r,user:r,sys:w,admin:rw"`
+	Secret   string `pex:"guest:,user:,sys:w,admin:rw"`
+	GStruct  `pex:"guest:r,user:r,sys:rw,admin:rw"`
+	Values   []GStruct
+	Array    [1]GStruct
+	Map      map[string]GStruct
+	Pointers map[string]*GStruct
+	Value    interface{}
+	Next     *PStruct
+}
+
+func TestRedact(t *testing.T) {
+	t.Run("TestRedactContainers", testRedactContainers)
+	t.Run("TestRedactCycle", testRedactCycle)
+	t.Run("TestRedactInterfaces", testRedactInterfaces)
+	t.Run("TestRedactErrors", testRedactErrors)
+}
+
+func testRedactContainers(t *testing.T) {
+	t.Parallel()
+
+	newPStruct := func() *PStruct {
+		return &PStruct{
+			Name:     "ABC",
+			Secret:   "DEF",
+			GStruct:  GStruct{Name: "GHI", Version: 1},
+			Values:   []GStruct{{Name: "JKL", Version: 2}},
+			Array:    [1]GStruct{{Name: "MNO", Version: 3}},
+			Map:      map[string]GStruct{"a": {Name: "PQR", Version: 4}},
+			Pointers: map[string]*GStruct{"b": {Name: "STU", Version: 5}},
+			Value:    GStruct{Name: "VWX", Version: 6},
+			Next:     &PStruct{Name: "YZ", Secret: "ABC"},
+		}
+	}
+
+	tables := []struct {
+		userType string
+		action   uint
+		expected *PStruct
+	}{
+		{"guest", ActionRead, &PStruct{
+			Name:     "ABC",
+			GStruct:  GStruct{Version: 1},
+			Values:   []GStruct{{Version: 2}},
+			Array:    [1]GStruct{{Version: 3}},
+			Map:      map[string]GStruct{"a": {Version: 4}},
+			Pointers: map[string]*GStruct{"b": {Version: 5}},
+			Value:    GStruct{Version: 6},
+			Next:     &PStruct{Name: "YZ"},
+		}},
+		{"admin", ActionRead, &PStruct{
+			Name:     "ABC",
+			Secret:   "DEF",
+			GStruct:  GStruct{Name: "GHI"},
+			Values:   []GStruct{{Name: "JKL"}},
+			Array:    [1]GStruct{{Name: "MNO"}},
+			Map:      map[string]GStruct{"a": {Name: "PQR"}},
+			Pointers: map[string]*GStruct{"b": {Name: "STU"}},
+			Value:    GStruct{Name: "VWX"},
+			Next:     &PStruct{Name: "YZ", Secret: "ABC"},
+		}},
+		{"user", ActionWrite, &PStruct{
+			Values:   []GStruct{{Version: 2}},
+			Array:    [1]GStruct{{Version: 3}},
+			Map:      map[string]GStruct{"a": {Version: 4}},
+			Pointers: map[string]*GStruct{"b": {Version: 5}},
+			Value:    GStruct{Version: 6},
+			Next:     &PStruct{},
+		}},
+	}
+
+	for _, table := range tables {
+		actual := newPStruct()
+		err := Redact(actual, table.userType, table.action)
+		if err != nil || !reflect.DeepEqual(actual, table.expected) {
+			t.Errorf("%s (userType = %s, action = %d) was incorrect, got: %+v, %v, want: %+v.",
+				t.Name(), table.userType, table.action, actual, err, table.expected)
+		}
+	}
+}
+
+func testRedactCycle(t *testing.T) {
+	t.Parallel()
+
+	cycle := &PStruct{Name: "ABC", Secret: "DEF"}
+	cycle.Next = cycle
+	cycle.Value = cycle
+	cycle.Pointers = map[string]*GStruct{"a": &cycle.GStruct}
+
+	if err := Redact(&cycle, "user", ActionRead); err != nil {
+		t.Fatalf("%s was incorrect, got: %v, want: nil.", t.Name(), err)
+	}
+	if cycle.Name != "ABC" || cycle.Secret != "" || cycle.Next != cycle {
+		t.Errorf("%s was incorrect, got: %+v.", t.Name(), cycle)
+	}
+}
+
+func testRedactInterfaces(t *testing.T) {
+	t.Parallel()
+
+	actual := map[string]interface{}{
+		"a": AStruct{Number: 1, Text: "ABC"},
+		"b": &AStruct{Number: 2, Text: "DEF"},
+		"c": []interface{}{AStruct{Number: 3}},
+		"d": nil,
+		"e": "GHI",
+	}
+	expected := map[string]interface{}{
+		"a": AStruct{},
+		"b": &AStruct{},
+		"c": []interface{}{AStruct{}},
+		"d": nil,
+		"e": "GHI",
+	}
+
+	if err := Redact(&actual, "guest", ActionRead); err != nil || !reflect.DeepEqual(actual, expected) {
+		t.Errorf("%s was incorrect, got: %+v, %v, want: %+v.", t.Name(), actual, err, expected)
+	}
+}
+
+func testRedactErrors(t *testing.T) {
+	t.Parallel()
+
+	var nilPointer *PStruct
+	baseJStruct := JStruct{Name: "ABC", Version: 1}
+
+	tables := []struct {
+		object      interface{}
+		expectedErr error
+	}{
+		{PStruct{}, ErrNotPointer},
+		{nilPointer, ErrNotPointer},
+		{nil, ErrNotPointer},
+		{&baseJStruct, ErrMalformedTag},
+		{&[]interface{}{1, &baseJStruct}, ErrMalformedTag},
+	}
+
+	for _, table := range tables {
+		err := Redact(table.object, "user", ActionRead)
+		if !errors.Is(err, table.expectedErr) {
+			t.Errorf("%s (object = %+v) was incorrect, got: %v, want: %v.", t.Name(), table.object, err, table.expectedErr)
+		}
+	}
+}
@@ -0,0 +1,98 @@
+package gopex
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+)
+
+// RoleHierarchy defines the roles each role inherits from. A role that is not listed in a permission
+// tag is allowed the actions of any of its parents, so with admin inheriting from user a tag like
+// "user:r" also grants read to admin. A role listed in the tag gets exactly the actions of its entry.
+type RoleHierarchy struct {
+	mutex   sync.RWMutex
+	parents map[string][]string
+}
+
+// NewRoleHierarchy returns an empty role hierarchy
+func NewRoleHierarchy() *RoleHierarchy {
+	return &RoleHierarchy{parents: map[string][]string{}}
+}
+
+// defaultRoles is the role hierarchy used by the functions of the package
+var defaultRoles = NewRoleHierarchy()
+
+// DefineRole sets the parents of a role in the role hierarchy used by the functions of the package.
+// See RoleHierarchy.Define.
+func DefineRole(role string, parents ...string) error {
+	return defaultRoles.Define(role, parents...)
+}
+
+// Define sets the parents of a role, replacing the previous ones. It returns an *Error wrapping
+// ErrRoleCycle, and leaves the hierarchy unchanged, if a role would end up inheriting from itself.
+func (h *RoleHierarchy) Define(role string, parents ...string) error {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	for _, parent := range parents {
+		if chain := h.chain(parent, role); chain != nil {
+			chain = append([]string{role}, chain...)
+			return &Error{Err: fmt.Errorf("%w: %s", ErrRoleCycle, strings.Join(chain, " -> "))}
+		}
+	}
+
+	h.parents[role] = append([]string{}, parents...)
+	return nil
+}
+
+// Parents returns the roles a role directly inherits from, in the order they were defined
+func (h *RoleHierarchy) Parents(role string) []string {
+	h.mutex.RLock()
+	defer h.mutex.RUnlock()
+
+	return append([]string{}, h.parents[role]...)
+}
+
+// chain returns the roles from a role to one of its ancestors, both included, or nil if the
+// role does not inherit from it
+func (h *RoleHierarchy) chain(role string, ancestor string) []string {
+	if role == ancestor {
+		return []string{role}
+	}
+
+	for _, parent := range h.parents[role] {
+		if chain := h.chain(parent, ancestor); chain != nil {
+			return append([]string{role}, chain...)
+		}
+	}
+
+	return nil
+}
+
+// mask returns the actions a role is allowed in a permission set. A role listed in the set gets its
+// entry, otherwise it gets the union of the actions of its parents. It returns false if neither the
+// role nor any of its ancestors is listed.
+func (h *RoleHierarchy) mask(permissions permissionSet, role string) (actionMask, bool) {
+	h.mutex.RLock()
+	defer h.mutex.RUnlock()
+
+	return h.resolve(permissions, role)
+}
+
+// resolve is like mask but expects the hierarchy to be locked
+func (h *RoleHierarchy) resolve(permissions permissionSet, role string) (actionMask, bool) {
+	if mask, ok := permissions[role]; ok {
+		return mask, true
+	}
+
+	var mask actionMask
+	found := false
+	for _, parent := range h.parents[role] {
+		if parentMask, ok := h.resolve(permissions, parent); ok {
+			mask |= parentMask
+			found = true
+		}
+	}
+
+	return mask, found
+}
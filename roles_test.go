@@ -0,0 +1,133 @@
+package gopex
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+// Struct with permissions for the roots of a role hierarchy
+type WStruct struct {
+	Name   string `pex:"viewer:r,editor:w"`
+	Draft  string `pex:"editor:rw"`
+	Secret string `pex:"viewer:r,owner:"`
+}
+
+func TestRoles(t *testing.T) {
+	t.Run("TestRoleHierarchyDefine", testRoleHierarchyDefine)
+	t.Run("TestRoleHierarchyMask", testRoleHierarchyMask)
+	t.Run("TestRoleHierarchyExtract", testRoleHierarchyExtract)
+	t.Run("TestDefineRole", testDefineRole)
+}
+
+func testRoleHierarchyDefine(t *testing.T) {
+	t.Parallel()
+
+	roles := NewRoleHierarchy()
+	tables := []struct {
+		role     string
+		parents  []string
+		expected string
+	}{
+		{"user", []string{"guest"}, ""},
+		{"admin", []string{"user", "sys"}, ""},
+		{"guest", []string{"admin"}, "gopex: role cycle: guest -> admin -> user -> guest"},
+		{"sys", []string{"sys"}, "gopex: role cycle: sys -> sys"},
+		{"guest", []string{"anonymous"}, ""},
+	}
+
+	for _, table := range tables {
+		err := roles.Define(table.role, table.parents...)
+		if table.expected == "" && err != nil ||
+			table.expected != "" && (err == nil || err.Error() != table.expected || !errors.Is(err, ErrRoleCycle)) {
+			t.Errorf("%s (role = %s, parents = %v) was incorrect, got: %v, want: %s.", t.Name(), table.role,
+				table.parents, err, table.expected)
+		}
+	}
+
+	if parents := roles.Parents("admin"); !reflect.DeepEqual(parents, []string{"user", "sys"}) {
+		t.Errorf("%s (role = admin) was incorrect, got: %v, want: %v.", t.Name(), parents, []string{"user", "sys"})
+	}
+	if parents := roles.Parents("sys"); len(parents) != 0 {
+		t.Errorf("%s (role = sys) was incorrect, got: %v, want: [].", t.Name(), parents)
+	}
+}
+
+func testRoleHierarchyMask(t *testing.T) {
+	t.Parallel()
+
+	roles := NewRoleHierarchy()
+	_ = roles.Define("user", "guest")
+	_ = roles.Define("sys", "guest")
+	_ = roles.Define("admin", "user", "sys")
+	extractor := NewExtractor(WithRoleHierarchy(roles))
+
+	tables := []struct {
+		tag      string
+		userType string
+		action   uint
+		expected bool
+	}{
+		{"guest:r", "admin", ActionRead, true},
+		{"guest:r", "user", ActionWrite, false},
+		{"user:r,sys:w", "admin", ActionRead, true},
+		{"user:r,sys:w", "admin", ActionWrite, true},
+		{"user:r,admin:", "admin", ActionRead, false},
+		{"user:rw", "guest", ActionRead, false},
+		{"guest:w", "other", ActionWrite, false},
+	}
+
+	for _, table := range tables {
+		permissions, _ := parsePermissionTag(table.tag)
+		result := extractor.grants(permissions, NewPrincipal(table.userType), table.action)
+		if result != table.expected {
+			t.Errorf("%s (tag = %s, userType = %s, action = %d) was incorrect, got: %t, want: %t.", t.Name(),
+				table.tag, table.userType, table.action, result, table.expected)
+		}
+	}
+}
+
+func testRoleHierarchyExtract(t *testing.T) {
+	t.Parallel()
+
+	roles := NewRoleHierarchy()
+	_ = roles.Define("editor", "viewer")
+	_ = roles.Define("owner", "editor")
+	extractor := NewExtractor(WithRoleHierarchy(roles))
+
+	object := WStruct{Name: "ABC", Draft: "DEF", Secret: "GHI"}
+	tables := []struct {
+		userType string
+		action   uint
+		expected interface{}
+	}{
+		{"viewer", ActionRead, map[string]interface{}{"Name": "ABC", "Secret": "GHI"}},
+		{"editor", ActionRead, map[string]interface{}{"Draft": "DEF", "Secret": "GHI"}},
+		{"editor", ActionWrite, map[string]interface{}{"Name": "ABC", "Draft": "DEF"}},
+		{"owner", ActionRead, map[string]interface{}{"Draft": "DEF"}},
+		{"owner", ActionWrite, map[string]interface{}{"Name": "ABC", "Draft": "DEF"}},
+	}
+
+	for _, table := range tables {
+		result, err := extractor.Extract(object, table.userType, table.action)
+		if err != nil || !reflect.DeepEqual(result, table.expected) {
+			t.Errorf("%s (object = %+v, userType = %s, action = %d) was incorrect, got: %+v (%v), want: %+v.",
+				t.Name(), object, table.userType, table.action, result, err, table.expected)
+		}
+	}
+}
+
+func testDefineRole(t *testing.T) {
+	t.Parallel()
+
+	if err := DefineRole("testDefineRoleChild", "testDefineRoleParent"); err != nil {
+		t.Fatalf("%s was incorrect, got: %v, want: <nil>.", t.Name(), err)
+	}
+
+	if !hasPermission("testDefineRoleParent:w", "testDefineRoleChild", ActionWrite) {
+		t.Errorf("%s (tag = testDefineRoleParent:w) was incorrect, got: false, want: true.", t.Name())
+	}
+	if err := DefineRole("testDefineRoleParent", "testDefineRoleChild"); !errors.Is(err, ErrRoleCycle) {
+		t.Errorf("%s was incorrect, got: %v, want: %v.", t.Name(), err, ErrRoleCycle)
+	}
+}
@@ -0,0 +1,229 @@
+package gopex
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+// actionMask is a set of actions, where the bit n is set if the action n is allowed
+type actionMask uint64
+
+// has returns true if the action is in the mask
+func (m actionMask) has(action uint) bool {
+	return action < 64 && m&(1<<action) != 0
+}
+
+// permissionSet is a parsed permission tag, mapping each user type to the actions it is allowed
+type permissionSet map[string]actionMask
+
+// TagError describes a malformed permission tag. Column is the position, starting at 1,
+// of the character of Tag where the problem was found.
+type TagError struct {
+	Struct string
+	Field  string
+	Tag    string
+	Column int
+	Msg    string
+}
+
+// Error returns the description of the error
+func (e *TagError) Error() string {
+	location := ""
+	if e.Field != "" {
+		location = " on " + fieldPath(e.Struct, e.Field)
+	}
+
+	return e.describe(location)
+}
+
+// describe returns the description of the error with the given location of the tag
+func (e *TagError) describe(location string) string {
+	return fmt.Sprintf("%s %q%s at column %d: %s", ErrMalformedTag, e.Tag, location, e.Column, e.Msg)
+}
+
+// Unwrap returns ErrMalformedTag so that errors.Is can be used to detect malformed tags
+func (e *TagError) Unwrap() error {
+	return ErrMalformedTag
+}
+
+// parsePermissionTag parses a non empty permission tag like "user:r,admin:rw". The grammar is a comma
+// separated list of entries, each one a user type and its permissions separated by a colon. Permissions
+// are either action letters, like "rw", or action names separated by '|', like "read|write".
+// Whitespace, empty entries, unknown actions and duplicate user types or actions are rejected.
+func parsePermissionTag(permissionTag string) (permissionSet, *TagError) {
+	permissions := permissionSet{}
+	fail := func(column int, format string, args ...interface{}) (permissionSet, *TagError) {
+		return nil, &TagError{Tag: permissionTag, Column: column + 1, Msg: fmt.Sprintf(format, args...)}
+	}
+
+	start := 0
+	for start <= len(permissionTag) {
+		end := strings.IndexByte(permissionTag[start:], ',')
+		if end < 0 {
+			end = len(permissionTag)
+		} else {
+			end += start
+		}
+		entry := permissionTag[start:end]
+
+		if entry == "" {
+			return fail(start, "empty entry")
+		}
+		if i := strings.IndexAny(entry, " \t\r\n"); i >= 0 {
+			return fail(start+i, "unexpected whitespace")
+		}
+
+		separator := strings.IndexByte(entry, ':')
+		if separator < 0 {
+			return fail(end, "missing ':' after user type %q", entry)
+		}
+		if separator == 0 {
+			return fail(start, "empty user type")
+		}
+
+		userType := entry[:separator]
+		if _, ok := permissions[userType]; ok {
+			return fail(start, "duplicate user type %q", userType)
+		}
+
+		if i := strings.IndexByte(entry[separator+1:], ':'); i >= 0 {
+			return fail(start+separator+1+i, "unexpected ':'")
+		}
+
+		// Split the permissions into action names or letters
+		var names []string
+		var columns []int
+		value := entry[separator+1:]
+		if strings.IndexByte(value, '|') >= 0 || len(value) > 1 && isRegisteredName(value) {
+			column := start + separator + 1
+			for _, name := range strings.Split(value, "|") {
+				names = append(names, name)
+				columns = append(columns, column)
+				column += len(name) + 1
+			}
+		} else {
+			for i := separator + 1; i < len(entry); i++ {
+				names = append(names, entry[i:i+1])
+				columns = append(columns, start+i)
+			}
+		}
+
+		var mask actionMask
+		for i, name := range names {
+			if name == "" {
+				return fail(columns[i], "empty permission")
+			}
+
+			action, ok := LookupAction(name)
+			if !ok {
+				return fail(columns[i], "unknown permission %q", name)
+			}
+			if mask.has(action) {
+				return fail(columns[i], "duplicate permission %q", name)
+			}
+			mask |= 1 << action
+		}
+		permissions[userType] = mask
+
+		start = end + 1
+	}
+
+	return permissions, nil
+}
+
+// parseAnnotation returns the annotation of a struct type, the permission tag of its blank fields like
+// _ struct{} `pex:"closed"`, or an empty string if it has none
+func parseAnnotation(reflectType reflect.Type, tagName string) (string, *TagError) {
+	annotation := ""
+	for i := 0; i < reflectType.NumField(); i++ {
+		field := reflectType.Field(i)
+		tag := field.Tag.Get(tagName)
+		if field.Name != "_" || tag == "" {
+			continue
+		}
+
+		fail := func(format string, args ...interface{}) (string, *TagError) {
+			return "", &TagError{Struct: reflectType.Name(), Field: field.Name, Tag: tag, Column: 1,
+				Msg: fmt.Sprintf(format, args...)}
+		}
+		if tag != AnnotationOpen && tag != AnnotationClosed {
+			return fail("unknown struct annotation")
+		}
+		if annotation != "" && annotation != tag {
+			return fail("conflicting struct annotation %q", annotation)
+		}
+		annotation = tag
+	}
+
+	return annotation, nil
+}
+
+// ValidateType checks the permission tags of a type and of every type reachable from it through
+// fields, pointers, slices, arrays and maps. It returns an *Error wrapping a *TagError for the
+// first malformed tag found, or nil if all of them are well formed.
+//
+// It is meant to be called in init functions or tests to make sure models are correctly tagged.
+func ValidateType(reflectType reflect.Type) error {
+	return defaultExtractor.ValidateType(reflectType)
+}
+
+// ValidateType checks the permission tags of a type like the ValidateType function, using the tag name
+// of the Extractor
+func (e *Extractor) ValidateType(reflectType reflect.Type) error {
+	if reflectType == nil {
+		return nil
+	}
+
+	rootType := reflectType
+	for rootType.Kind() == reflect.Ptr {
+		rootType = rootType.Elem()
+	}
+
+	return validateType(reflectType, e.tagName, rootType.Name(), map[reflect.Type]bool{})
+}
+
+// validateType validates a type, skipping the ones already visited
+func validateType(reflectType reflect.Type, tagName string, path string, visited map[reflect.Type]bool) error {
+	if visited[reflectType] {
+		return nil
+	}
+	visited[reflectType] = true
+
+	switch reflectType.Kind() {
+	case reflect.Ptr, reflect.Slice, reflect.Array:
+		return validateType(reflectType.Elem(), tagName, path, visited)
+	case reflect.Map:
+		if err := validateType(reflectType.Key(), tagName, path, visited); err != nil {
+			return err
+		}
+		return validateType(reflectType.Elem(), tagName, path, visited)
+	case reflect.Struct:
+		if _, tagErr := parseAnnotation(reflectType, tagName); tagErr != nil {
+			return &Error{Path: fieldPath(path, tagErr.Field), Err: tagErr}
+		}
+
+		for i := 0; i < reflectType.NumField(); i++ {
+			// Unexported fields are skipped, except embedded structs whose exported fields are promoted
+			field := reflectType.Field(i)
+			if field.PkgPath != "" && (!field.Anonymous || field.Type.Kind() != reflect.Struct) {
+				continue
+			}
+
+			fieldPath := fieldPath(path, field.Name)
+			if permissionTag := field.Tag.Get(tagName); permissionTag != "" {
+				if _, tagErr := parsePermissionTag(permissionTag); tagErr != nil {
+					tagErr.Struct = reflectType.Name()
+					tagErr.Field = field.Name
+					return &Error{Path: fieldPath, Err: tagErr}
+				}
+			}
+
+			if err := validateType(field.Type, tagName, fieldPath, visited); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
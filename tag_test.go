@@ -0,0 +1,183 @@
+package gopex
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+// Struct with nested malformed permission tags
+type KStruct struct {
+	Name     string    `pex:"user:r,admin:rw"`
+	Children []KStruct `pex:"user:r,admin:rw"`
+	Parent   *KStruct
+	Values   map[string]JStruct
+}
+
+func TestParsePermissionTag(t *testing.T) {
+	t.Run("TestParsePermissionTagValid", testParsePermissionTagValid)
+	t.Run("TestParsePermissionTagInvalid", testParsePermissionTagInvalid)
+}
+
+func testParsePermissionTagValid(t *testing.T) {
+	t.Parallel()
+
+	tables := []struct {
+		tag      string
+		expected permissionSet
+	}{
+		{"user:r", permissionSet{"user": 1 << ActionRead}},
+		{"guest:,user:r,sys:w,admin:rw", permissionSet{
+			"guest": 0,
+			"user":  1 << ActionRead,
+			"sys":   1 << ActionWrite,
+			"admin": 1<<ActionRead | 1<<ActionWrite,
+		}},
+		{"admin:wr", permissionSet{"admin": 1<<ActionRead | 1<<ActionWrite}},
+		{"*:r,admin:rw", permissionSet{"*": 1 << ActionRead, "admin": 1<<ActionRead | 1<<ActionWrite}},
+		{"user:read,admin:read|write", permissionSet{"user": 1 << ActionRead, "admin": 1<<ActionRead | 1<<ActionWrite}},
+	}
+
+	for _, table := range tables {
+		actual, err := parsePermissionTag(table.tag)
+		if err != nil || !reflect.DeepEqual(actual, table.expected) {
+			t.Errorf("%s (tag = %q) was incorrect, got: %+v, %v, want: %+v.",
+				t.Name(), table.tag, actual, err, table.expected)
+		}
+	}
+}
+
+func testParsePermissionTagInvalid(t *testing.T) {
+	t.Parallel()
+
+	tables := []struct {
+		tag            string
+		expectedColumn int
+		expectedMsg    string
+	}{
+		{"user,admin:rw", 5, `missing ':' after user type "user"`},
+		{"user:r,", 8, "empty entry"},
+		{",user:r", 1, "empty entry"},
+		{"user:r,,admin:rw", 8, "empty entry"},
+		{"user:r, admin:rw", 8, "unexpected whitespace"},
+		{"user:r ", 7, "unexpected whitespace"},
+		{":r", 1, "empty user type"},
+		{"user:x", 6, `unknown permission "x"`},
+		{"user:rwx", 8, `unknown permission "x"`},
+		{"user:rr", 7, `duplicate permission "r"`},
+		{"user:r:w", 7, "unexpected ':'"},
+		{"user:r,admin:rw,user:w", 17, `duplicate user type "user"`},
+		{"user:read|", 11, "empty permission"},
+		{"user:read|list", 11, `unknown permission "list"`},
+		{"user:r|write|read", 14, `duplicate permission "read"`},
+	}
+
+	for _, table := range tables {
+		actual, err := parsePermissionTag(table.tag)
+		if actual != nil || err == nil || err.Column != table.expectedColumn || err.Msg != table.expectedMsg {
+			t.Errorf("%s (tag = %q) was incorrect, got: %+v, %+v, want: column %d, %s.",
+				t.Name(), table.tag, actual, err, table.expectedColumn, table.expectedMsg)
+		}
+	}
+}
+
+// Struct with a malformed annotation
+type ZStruct struct {
+	_    struct{} `pex:"private"`
+	Name string
+}
+
+// Struct embedded unexported with a malformed tag
+type malformedEmbedded struct {
+	Version uint `pex:"user"`
+}
+
+// Struct with an unexported embedded struct with a malformed tag
+type AGStruct struct {
+	malformedEmbedded
+	Name string `pex:"user:r"`
+}
+
+func TestValidateType(t *testing.T) {
+	t.Run("TestValidateTypeValid", testValidateTypeValid)
+	t.Run("TestValidateTypeInvalid", testValidateTypeInvalid)
+}
+
+func testValidateTypeValid(t *testing.T) {
+	t.Parallel()
+
+	tables := []struct {
+		reflectType reflect.Type
+	}{
+		{reflect.TypeOf(AStruct{})},
+		{reflect.TypeOf(&BStruct{})},
+		{reflect.TypeOf([]CStruct{})},
+		{reflect.TypeOf(map[string]EStruct{})},
+		{reflect.TypeOf(FStruct{})},
+		{reflect.TypeOf(YStruct{})},
+		{reflect.TypeOf(10)},
+		{nil},
+	}
+
+	for _, table := range tables {
+		if err := ValidateType(table.reflectType); err != nil {
+			t.Errorf("%s (type = %v) was incorrect, got: %v, want: nil.", t.Name(), table.reflectType, err)
+		}
+	}
+}
+
+func testValidateTypeInvalid(t *testing.T) {
+	t.Parallel()
+
+	tables := []struct {
+		reflectType    reflect.Type
+		expectedPath   string
+		expectedStruct string
+		expectedField  string
+	}{
+		{reflect.TypeOf(JStruct{}), "JStruct.Version", "JStruct", "Version"},
+		{reflect.TypeOf(&JStruct{}), "JStruct.Version", "JStruct", "Version"},
+		{reflect.TypeOf([]JStruct{}), "Version", "JStruct", "Version"},
+		{reflect.TypeOf(KStruct{}), "KStruct.Values.Version", "JStruct", "Version"},
+		{reflect.TypeOf(ZStruct{}), "ZStruct._", "ZStruct", "_"},
+		{reflect.TypeOf(AGStruct{}), "AGStruct.malformedEmbedded.Version", "malformedEmbedded", "Version"},
+	}
+
+	for _, table := range tables {
+		err := ValidateType(table.reflectType)
+
+		var pexErr *Error
+		var tagErr *TagError
+		if !errors.As(err, &pexErr) || pexErr.Path != table.expectedPath ||
+			!errors.As(err, &tagErr) || tagErr.Struct != table.expectedStruct || tagErr.Field != table.expectedField ||
+			!errors.Is(err, ErrMalformedTag) {
+			t.Errorf("%s (type = %v) was incorrect, got: %v, want: %s.", t.Name(), table.reflectType, err, table.expectedPath)
+		}
+	}
+	expected := `gopex: JStruct.Version: malformed permission tag "user,admin:rw" at column 5: ` +
+		`missing ':' after user type "user"`
+	if err := ValidateType(reflect.TypeOf(JStruct{})); err == nil || err.Error() != expected {
+		t.Errorf("%s was incorrect, got: %v, want: %s.", t.Name(), err, expected)
+	}
+}
+
+func TestHasPermissionMalformedTag(t *testing.T) {
+	t.Parallel()
+
+	tables := []struct {
+		tag      string
+		userType string
+		action   uint
+	}{
+		{"user,admin:rw", "admin", ActionRead},
+		{"user:r,", "user", ActionRead},
+		{"admin:rwx", "admin", ActionWrite},
+	}
+
+	for _, table := range tables {
+		if hasPermission(table.tag, table.userType, table.action) {
+			t.Errorf("%s (tag = %q, userType = %s, action = %d) was incorrect, got: true, want: false.",
+				t.Name(), table.tag, table.userType, table.action)
+		}
+	}
+}
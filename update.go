@@ -0,0 +1,318 @@
+package gopex
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+)
+
+// ApplyUpdate applies a partial update onto an object, only touching the fields the user has permission
+// to write. The fields the user can not write are left untouched even if present in the update.
+//
+// The object must be a non nil pointer to a struct. The update is either a value or pointer of the same
+// type, whose non zero fields are applied, or a JSON document as a []byte, json.RawMessage or decoded
+// map[string]interface{}, whose members are applied. In both cases nested structs are updated field by
+// field instead of being replaced. Slices, arrays and maps are replaced, and the fields the user can not
+// write of the structs they hold are zero, unless the element is the same as the current one at the same
+// index or key, in which case they are kept.
+//
+// It returns the JSON pointers of the fields whose value changed, like "/addresses/2/verified" or
+// "/name", in the order of the struct fields or of the sorted members of the JSON document.
+func ApplyUpdate(dst interface{}, src interface{}, userType string) ([]string, error) {
+	return defaultExtractor.ApplyUpdateFor(dst, src, NewPrincipal(userType))
+}
+
+// ApplyUpdateFor is like ApplyUpdate but for a principal that can hold several roles
+func ApplyUpdateFor(dst interface{}, src interface{}, principal Principal) ([]string, error) {
+	return defaultExtractor.ApplyUpdateFor(dst, src, principal)
+}
+
+// ApplyUpdate applies a partial update onto an object, like the ApplyUpdate function
+func (e *Extractor) ApplyUpdate(dst interface{}, src interface{}, userType string) ([]string, error) {
+	return e.ApplyUpdateFor(dst, src, NewPrincipal(userType))
+}
+
+// ApplyUpdateFor is like ApplyUpdate but for a principal that can hold several roles
+func (e *Extractor) ApplyUpdateFor(dst interface{}, src interface{}, principal Principal) ([]string, error) {
+	reflectValue := reflect.ValueOf(dst)
+	if reflectValue.Kind() != reflect.Ptr || reflectValue.IsNil() {
+		return nil, &Error{Err: fmt.Errorf("%w %T", ErrNotPointer, dst)}
+	}
+
+	target := allocElem(reflectValue)
+	path := target.Type().Name()
+	if target.Kind() != reflect.Struct || e.isLeaf(target.Type()) {
+		return nil, &Error{Path: path, Err: fmt.Errorf("%w %s, expected a struct", ErrUnsupportedKind, target.Type())}
+	}
+
+	src, err := decodePayload(src)
+	if err != nil {
+		return nil, err
+	}
+
+	updater := &updater{extractor: e, principal: principal, action: ActionWrite}
+	if object, ok := src.(map[string]interface{}); ok {
+		err = updater.updateObject(target, object, path, "")
+	} else if source := getReflectValue(src); source != nil {
+		if source.Type() != target.Type() {
+			return nil, &Error{Path: path, Err: fmt.Errorf("%w %T, expected %s", ErrTypeMismatch, src, target.Type())}
+		}
+		err = updater.updateStruct(target, *source, path, "")
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return updater.changed, nil
+}
+
+// updater applies updates onto structs, only touching the fields a principal has access
+type updater struct {
+	extractor *Extractor
+	principal Principal
+	action    uint
+	// changed are the JSON pointers of the fields whose value changed
+	changed []string
+}
+
+// updateStruct applies the non zero fields of a struct onto another one of the same type
+func (u *updater) updateStruct(dst reflect.Value, src reflect.Value, path string, pointer string) error {
+	for _, field := range u.extractor.plans.get(dst.Type()).outputs {
+		srcField, ok := fieldByIndex(src, field.index)
+		if !ok || srcField.IsZero() {
+			continue
+		}
+
+		fieldPath := fieldPath(path, field.path)
+		allowed, err := field.allows(u.extractor, u.principal, u.action)
+		if err != nil {
+			return &Error{Path: fieldPath, Err: err}
+		}
+		if !allowed {
+			continue
+		}
+
+		dstField := allocFieldByIndex(dst, field.index)
+		fieldPointer := pointerPath(pointer, field.name)
+		if u.isStruct(dstField.Type()) && !field.quoted {
+			err := u.updateNested(dstField, func(target reflect.Value) error {
+				return u.updateStruct(target, indirect(srcField), fieldPath, fieldPointer)
+			})
+			if err != nil {
+				return err
+			}
+			continue
+		}
+
+		value, err := u.restrict(srcField, dstField, fieldPath)
+		if err != nil {
+			return err
+		}
+		u.set(dstField, value, fieldPointer)
+	}
+
+	return nil
+}
+
+// updateObject applies the members of a decoded JSON object onto a struct
+func (u *updater) updateObject(dst reflect.Value, object map[string]interface{}, path string, pointer string) error {
+	plan := u.extractor.plans.get(dst.Type())
+	for _, key := range sortedKeys(object) {
+		field := plan.output(key)
+		if field == nil {
+			continue
+		}
+
+		fieldPath := fieldPath(path, field.path)
+		allowed, err := field.allows(u.extractor, u.principal, u.action)
+		if err != nil {
+			return &Error{Path: fieldPath, Err: err}
+		}
+		if !allowed {
+			continue
+		}
+
+		dstField := allocFieldByIndex(dst, field.index)
+		fieldPointer := pointerPath(pointer, field.name)
+		if member, ok := object[key].(map[string]interface{}); ok && u.isStruct(dstField.Type()) && !field.quoted {
+			err := u.updateNested(dstField, func(target reflect.Value) error {
+				return u.updateObject(target, member, fieldPath, fieldPointer)
+			})
+			if err != nil {
+				return err
+			}
+			continue
+		}
+
+		value, err := decodeMember(object[key], dstField.Type(), field.quoted)
+		if err != nil {
+			return &Error{Path: fieldPath, Err: err}
+		}
+		if value, err = u.restrict(value, dstField, fieldPath); err != nil {
+			return err
+		}
+		u.set(dstField, value, fieldPointer)
+	}
+
+	return nil
+}
+
+// updateNested updates the struct a field holds or points to, allocating it if the field is a nil
+// pointer. The pointer is set back to nil if no field of the struct changed.
+func (u *updater) updateNested(field reflect.Value, update func(target reflect.Value) error) error {
+	changed := len(u.changed)
+	wasNil := field.Kind() == reflect.Ptr && field.IsNil()
+	if err := update(allocElem(field)); err != nil {
+		return err
+	}
+
+	if wasNil && len(u.changed) == changed {
+		field.Set(reflect.Zero(field.Type()))
+	}
+	return nil
+}
+
+// set sets a field to a value, recording its pointer if the value changed
+func (u *updater) set(dst reflect.Value, value reflect.Value, pointer string) {
+	if reflect.DeepEqual(dst.Interface(), value.Interface()) {
+		return
+	}
+
+	dst.Set(value)
+	u.changed = append(u.changed, pointer)
+}
+
+// restrict returns a copy of a value to be set onto a field, where the fields the principal can not write
+// of the structs held in slices, arrays, maps and pointers are zero, unless the other fields are the same
+// as the ones of the element at the same index or key of the current value of the field, in which case
+// they keep its values
+func (u *updater) restrict(value reflect.Value, current reflect.Value, path string) (reflect.Value, error) {
+	cleaner := &cleaner{extractor: u.extractor, principal: u.principal, action: u.action,
+		copies: map[visit]reflect.Value{}}
+	copied, err := cleaner.copyValue(value, path)
+	if err != nil {
+		return reflect.Value{}, err
+	}
+
+	u.restore(copied, current, map[visit]bool{})
+	return copied, nil
+}
+
+// restore sets the fields the principal can not write of the unchanged structs in a copied value to the
+// ones in the current value. Pointers already visited are skipped to stop cycles.
+func (u *updater) restore(value reflect.Value, current reflect.Value, visited map[visit]bool) {
+	if !current.IsValid() || value.Kind() != reflect.Ptr && u.extractor.isLeaf(value.Type()) {
+		return
+	}
+
+	switch value.Kind() {
+	case reflect.Ptr:
+		key := visit{pointer: value.Pointer(), reflectType: value.Type()}
+		if value.IsNil() || current.IsNil() || visited[key] {
+			return
+		}
+		visited[key] = true
+		u.restore(value.Elem(), current.Elem(), visited)
+	case reflect.Struct:
+		// The fields that can not be written are only restored if the ones that can are unchanged, so that
+		// they are not moved onto other content
+		var denied []*fieldPlan
+		unchanged := true
+		for _, field := range u.extractor.plans.get(value.Type()).fields {
+			// Malformed tags were already reported while copying the value
+			allowed, _ := field.allows(u.extractor, u.principal, u.action)
+			if !allowed {
+				denied = append(denied, field)
+				continue
+			}
+
+			fieldValue := value.FieldByIndex(field.index)
+			currentField := current.FieldByIndex(field.index)
+			u.restore(fieldValue, currentField, visited)
+			unchanged = unchanged && reflect.DeepEqual(fieldValue.Interface(), currentField.Interface())
+		}
+
+		for _, field := range denied {
+			if unchanged {
+				value.FieldByIndex(field.index).Set(current.FieldByIndex(field.index))
+			}
+		}
+	case reflect.Slice, reflect.Array:
+		for i := 0; i < value.Len() && i < current.Len(); i++ {
+			u.restore(value.Index(i), current.Index(i), visited)
+		}
+	case reflect.Map:
+		for _, key := range value.MapKeys() {
+			elem := reflect.New(value.Type().Elem()).Elem()
+			elem.Set(value.MapIndex(key))
+			u.restore(elem, current.MapIndex(key), visited)
+			value.SetMapIndex(key, elem)
+		}
+	}
+}
+
+// isStruct returns true if the values of a type, or of the type it points to, are structs walked field
+// by field
+func (u *updater) isStruct(reflectType reflect.Type) bool {
+	for reflectType.Kind() == reflect.Ptr {
+		reflectType = reflectType.Elem()
+	}
+
+	return reflectType.Kind() == reflect.Struct && !u.extractor.isLeaf(reflectType)
+}
+
+// decodeMember decodes a member of a decoded JSON object into a value of a type. If quoted the member
+// is a JSON string holding the encoded value, as done by the JSON string option.
+func decodeMember(member interface{}, reflectType reflect.Type, quoted bool) (reflect.Value, error) {
+	var data []byte
+	if text, ok := member.(string); ok && quoted {
+		data = []byte(text)
+	} else {
+		var err error
+		if data, err = json.Marshal(member); err != nil {
+			return reflect.Value{}, err
+		}
+	}
+
+	value := reflect.New(reflectType)
+	if err := json.Unmarshal(data, value.Interface()); err != nil {
+		return reflect.Value{}, err
+	}
+
+	return value.Elem(), nil
+}
+
+// allocElem returns the value a pointer points to, following pointers to pointers and allocating the
+// nil ones. Values that are not pointers are returned as they are.
+func allocElem(value reflect.Value) reflect.Value {
+	for value.Kind() == reflect.Ptr {
+		if value.IsNil() {
+			value.Set(reflect.New(value.Type().Elem()))
+		}
+		value = value.Elem()
+	}
+
+	return value
+}
+
+// allocFieldByIndex returns the field of a struct value by its index sequence, allocating the nil
+// embedded pointers it is promoted through
+func allocFieldByIndex(value reflect.Value, index []int) reflect.Value {
+	for i, x := range index {
+		if i > 0 {
+			value = allocElem(value)
+		}
+		value = value.Field(x)
+	}
+
+	return value
+}
+
+// indirect returns the value a pointer points to, following pointers to pointers
+func indirect(value reflect.Value) reflect.Value {
+	for value.Kind() == reflect.Ptr && !value.IsNil() {
+		value = value.Elem()
+	}
+
+	return value
+}
@@ -0,0 +1,144 @@
+package gopex
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestApplyUpdate(t *testing.T) {
+	t.Run("TestApplyUpdateStruct", testApplyUpdateStruct)
+	t.Run("TestApplyUpdateJSON", testApplyUpdateJSON)
+	t.Run("TestApplyUpdateErrors", testApplyUpdateErrors)
+}
+
+func testApplyUpdateStruct(t *testing.T) {
+	t.Parallel()
+
+	newADStruct := func() *ADStruct {
+		return &ADStruct{Name: "ABC", Salary: 10, GStruct: GStruct{Name: "DEF", Version: 1}}
+	}
+	update := ADStruct{
+		Name:      "GHI",
+		Salary:    20,
+		Addresses: []ACStruct{{Street: "PQR", Verified: true}},
+		Main:      &ACStruct{Street: "JKL", Verified: true},
+		GStruct:   GStruct{Name: "MNO", Version: 2},
+	}
+
+	tables := []struct {
+		userType string
+		expected *ADStruct
+		changed  []string
+	}{
+		{"user", &ADStruct{Name: "GHI", Salary: 10, Addresses: []ACStruct{{Street: "PQR"}},
+			Main: &ACStruct{Street: "JKL"}, GStruct: GStruct{Name: "DEF", Version: 2}},
+			[]string{"/name", "/addresses", "/main/street", "/Version"}},
+		{"admin", &ADStruct{Name: "GHI", Salary: 20, Addresses: []ACStruct{{Street: "PQR", Verified: true}},
+			Main: &ACStruct{Street: "JKL", Verified: true}, GStruct: GStruct{Name: "MNO", Version: 1}},
+			[]string{"/name", "/salary", "/addresses", "/main/street", "/main/verified", "/Name"}},
+		{"guest", &ADStruct{Name: "ABC", Salary: 10, GStruct: GStruct{Name: "DEF", Version: 2}},
+			[]string{"/Version"}},
+	}
+
+	for _, table := range tables {
+		actual := newADStruct()
+		changed, err := ApplyUpdate(actual, &update, table.userType)
+		if err != nil || !reflect.DeepEqual(actual, table.expected) || !reflect.DeepEqual(changed, table.changed) {
+			t.Errorf("%s (userType = %s) was incorrect, got: %+v, %v, %v, want: %+v, %v.", t.Name(), table.userType,
+				actual, changed, err, table.expected, table.changed)
+		}
+	}
+}
+
+func testApplyUpdateJSON(t *testing.T) {
+	t.Parallel()
+
+	newADStruct := func() *ADStruct {
+		return &ADStruct{
+			Name:      "ABC",
+			Salary:    10,
+			Addresses: []ACStruct{{Street: "DEF"}},
+			Main:      &ACStruct{Street: "GHI", Verified: true},
+		}
+	}
+
+	tables := []struct {
+		update   interface{}
+		userType string
+		expected *ADStruct
+		changed  []string
+	}{
+		{[]byte(`{"name":"JKL","salary":20,"addresses":[{"street":"MNO","verified":true}],"main":{"street":"PQR"}}`),
+			"user", &ADStruct{Name: "JKL", Salary: 10, Addresses: []ACStruct{{Street: "MNO"}},
+				Main: &ACStruct{Street: "PQR", Verified: true}}, []string{"/addresses", "/main/street", "/name"}},
+		{map[string]interface{}{"Salary": 20, "main": nil, "unknown": true, "name": "ABC"}, "admin",
+			&ADStruct{Name: "ABC", Salary: 20, Addresses: []ACStruct{{Street: "DEF"}}}, []string{"/salary", "/main"}},
+		{map[string]interface{}{"main": map[string]interface{}{"verified": false}}, "user", newADStruct(), nil},
+		{[]byte(`{"contacts":{"home":{"street":"STU","verified":true}}}`), "user", &ADStruct{Name: "ABC", Salary: 10,
+			Addresses: []ACStruct{{Street: "DEF"}}, Contacts: map[string]ACStruct{"home": {Street: "STU"}},
+			Main: &ACStruct{Street: "GHI", Verified: true}}, []string{"/contacts"}},
+	}
+
+	for _, table := range tables {
+		actual := newADStruct()
+		changed, err := ApplyUpdate(actual, table.update, table.userType)
+		if err != nil || !reflect.DeepEqual(actual, table.expected) || !reflect.DeepEqual(changed, table.changed) {
+			t.Errorf("%s (update = %v, userType = %s) was incorrect, got: %+v, %v, %v, want: %+v, %v.", t.Name(),
+				table.update, table.userType, actual, changed, err, table.expected, table.changed)
+		}
+	}
+
+	var empty ADStruct
+	changed, err := ApplyUpdate(&empty, map[string]interface{}{"main": map[string]interface{}{"verified": true}}, "user")
+	if err != nil || empty.Main != nil || changed != nil {
+		t.Errorf("%s was incorrect, got: %+v, %v, %v, want: %+v.", t.Name(), empty, changed, err, ADStruct{})
+	}
+
+	addresses := []struct {
+		update   string
+		expected []ACStruct
+	}{
+		{`[{"street":"ABC"},{"street":"GHI"}]`, []ACStruct{{Street: "ABC", Verified: true}, {Street: "GHI"}}},
+		{`[{"street":"DEF"},{"street":"ABC"}]`, []ACStruct{{Street: "DEF"}, {Street: "ABC"}}},
+		{`[{"street":"GHI"}]`, []ACStruct{{Street: "GHI"}}},
+		{`[{"street":"ABC","verified":false},{"street":"DEF"},{"street":"GHI","verified":true}]`,
+			[]ACStruct{{Street: "ABC", Verified: true}, {Street: "DEF", Verified: true}, {Street: "GHI"}}},
+	}
+
+	for _, table := range addresses {
+		actual := ADStruct{Addresses: []ACStruct{{Street: "ABC", Verified: true}, {Street: "DEF", Verified: true}}}
+		update := []byte(`{"addresses":` + table.update + `}`)
+		changed, err := ApplyUpdate(&actual, update, "user")
+		if err != nil || !reflect.DeepEqual(actual.Addresses, table.expected) {
+			t.Errorf("%s (addresses = %s) was incorrect, got: %+v, %v, %v, want: %+v.", t.Name(), table.update,
+				actual.Addresses, changed, err, table.expected)
+		}
+	}
+}
+
+func testApplyUpdateErrors(t *testing.T) {
+	t.Parallel()
+
+	tables := []struct {
+		dst      interface{}
+		src      interface{}
+		expected error
+	}{
+		{ADStruct{}, ADStruct{}, ErrNotPointer},
+		{(*ADStruct)(nil), ADStruct{}, ErrNotPointer},
+		{&[]ADStruct{}, ADStruct{}, ErrUnsupportedKind},
+		{&ADStruct{}, ACStruct{}, ErrTypeMismatch},
+		{&ADStruct{}, map[string]interface{}{"salary": "ABC"}, nil},
+		{&JStruct{}, JStruct{Version: 1}, ErrMalformedTag},
+	}
+
+	for _, table := range tables {
+		changed, err := ApplyUpdate(table.dst, table.src, "admin")
+		var pexErr *Error
+		if changed != nil || !errors.As(err, &pexErr) || table.expected != nil && !errors.Is(err, table.expected) {
+			t.Errorf("%s (dst = %T, src = %v) was incorrect, got: %v, %v, want: %v.", t.Name(), table.dst, table.src,
+				changed, err, table.expected)
+		}
+	}
+}
@@ -0,0 +1,278 @@
+package gopex
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// FilterPayload removes from a decoded request body the fields a user has not permission to write in the
+// type it is going to be decoded into. The payload is either the JSON document as a []byte or
+// json.RawMessage, or the result of decoding it into an interface{}, like a map[string]interface{}.
+//
+// It returns the filtered payload, which does not share maps or slices with the given one, and the
+// JSON pointers of the removed fields, like "/addresses/2/verified", so that handlers can either reject
+// the request or go on with the filtered payload. Keys that do not match any field are kept.
+func FilterPayload(payload interface{}, reflectType reflect.Type, userType string) (interface{}, []string, error) {
+	return defaultExtractor.FilterPayloadFor(payload, reflectType, NewPrincipal(userType))
+}
+
+// FilterPayloadFor is like FilterPayload but for a principal that can hold several roles
+func FilterPayloadFor(payload interface{}, reflectType reflect.Type,
+	principal Principal) (interface{}, []string, error) {
+	return defaultExtractor.FilterPayloadFor(payload, reflectType, principal)
+}
+
+// FilterPayload removes from a decoded request body the fields a user has not permission to write, like
+// the FilterPayload function
+func (e *Extractor) FilterPayload(payload interface{}, reflectType reflect.Type,
+	userType string) (interface{}, []string, error) {
+	return e.FilterPayloadFor(payload, reflectType, NewPrincipal(userType))
+}
+
+// FilterPayloadFor is like FilterPayload but for a principal that can hold several roles
+func (e *Extractor) FilterPayloadFor(payload interface{}, reflectType reflect.Type,
+	principal Principal) (interface{}, []string, error) {
+	payload, err := decodePayload(payload)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	rootType := reflectType
+	for rootType != nil && rootType.Kind() == reflect.Ptr {
+		rootType = rootType.Elem()
+	}
+	if rootType == nil {
+		return payload, nil, nil
+	}
+
+	filter := &payloadFilter{extractor: e, principal: principal, action: ActionWrite}
+	filtered, err := filter.filterValue(payload, rootType, rootType.Name(), "")
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return filtered, filter.forbidden, nil
+}
+
+// ValidateWrite checks that a user has permission to write every field of a request body, walking the
+// JSON document like FilterPayload. It returns a *ForbiddenError listing every field the user has not
+// permission to write, or nil if there is none.
+func ValidateWrite(payload interface{}, reflectType reflect.Type, userType string) error {
+	return defaultExtractor.ValidateWriteFor(payload, reflectType, NewPrincipal(userType))
+}
+
+// ValidateWriteFor is like ValidateWrite but for a principal that can hold several roles
+func ValidateWriteFor(payload interface{}, reflectType reflect.Type, principal Principal) error {
+	return defaultExtractor.ValidateWriteFor(payload, reflectType, principal)
+}
+
+// ValidateWrite checks that a user has permission to write every field of a request body, like the
+// ValidateWrite function
+func (e *Extractor) ValidateWrite(payload interface{}, reflectType reflect.Type, userType string) error {
+	return e.ValidateWriteFor(payload, reflectType, NewPrincipal(userType))
+}
+
+// ValidateWriteFor is like ValidateWrite but for a principal that can hold several roles
+func (e *Extractor) ValidateWriteFor(payload interface{}, reflectType reflect.Type, principal Principal) error {
+	_, forbidden, err := e.FilterPayloadFor(payload, reflectType, principal)
+	if err != nil {
+		return err
+	}
+	if len(forbidden) > 0 {
+		return &ForbiddenError{Violations: newViolations(forbidden, principal, ActionWrite)}
+	}
+
+	return nil
+}
+
+// decodePayload decodes a JSON document given as a []byte or json.RawMessage, keeping numbers as
+// json.Number. Documents followed by other data are rejected. Other payloads are returned as they are.
+func decodePayload(payload interface{}) (interface{}, error) {
+	if document, ok := payload.(json.RawMessage); ok {
+		payload = []byte(document)
+	}
+
+	document, ok := payload.([]byte)
+	if !ok {
+		return payload, nil
+	}
+
+	var decoded interface{}
+	decoder := json.NewDecoder(bytes.NewReader(document))
+	decoder.UseNumber()
+	if err := decoder.Decode(&decoded); err != nil {
+		return nil, &Error{Err: err}
+	}
+	if err := decoder.Decode(new(interface{})); err != io.EOF {
+		return nil, &Error{Err: errors.New("invalid data after the JSON document")}
+	}
+
+	return decoded, nil
+}
+
+// payloadFilter removes the fields a principal has not access from decoded JSON documents
+type payloadFilter struct {
+	extractor *Extractor
+	principal Principal
+	action    uint
+	// forbidden are the JSON pointers of the removed fields
+	forbidden []string
+	// unknown are the JSON pointers of the keys that do not match any field
+	unknown []string
+}
+
+// filterValue returns a copy of a decoded JSON value without the fields the principal has not access in the
+// type it is going to be decoded into. Path is the struct path of the value and pointer its JSON pointer.
+func (f *payloadFilter) filterValue(value interface{}, reflectType reflect.Type, path string,
+	pointer string) (interface{}, error) {
+	for reflectType.Kind() == reflect.Ptr {
+		reflectType = reflectType.Elem()
+	}
+	if f.extractor.isLeaf(reflectType) {
+		return value, nil
+	}
+
+	switch reflectType.Kind() {
+	case reflect.Struct:
+		object, ok := value.(map[string]interface{})
+		if !ok {
+			return value, nil
+		}
+		return f.filterObject(object, reflectType, path, pointer)
+	case reflect.Slice, reflect.Array:
+		elements, ok := value.([]interface{})
+		if !ok {
+			return value, nil
+		}
+
+		result := make([]interface{}, len(elements))
+		for i, element := range elements {
+			filtered, err := f.filterValue(element, reflectType.Elem(), indexPath(path, i),
+				pointerPath(pointer, strconv.Itoa(i)))
+			if err != nil {
+				return nil, err
+			}
+			result[i] = filtered
+		}
+		return result, nil
+	case reflect.Map:
+		object, ok := value.(map[string]interface{})
+		if !ok {
+			return value, nil
+		}
+
+		result := make(map[string]interface{}, len(object))
+		for _, key := range sortedKeys(object) {
+			filtered, err := f.filterValue(object[key], reflectType.Elem(), path+"["+key+"]", pointerPath(pointer, key))
+			if err != nil {
+				return nil, err
+			}
+			result[key] = filtered
+		}
+		return result, nil
+	default:
+		return value, nil
+	}
+}
+
+// filterObject returns a copy of a decoded JSON object without the fields the principal has not access
+// in the struct type it is going to be decoded into
+func (f *payloadFilter) filterObject(object map[string]interface{}, reflectType reflect.Type, path string,
+	pointer string) (interface{}, error) {
+	plan := f.extractor.plans.get(reflectType)
+	result := make(map[string]interface{}, len(object))
+	keys := make(map[*fieldPlan]string, len(object))
+	for _, key := range sortedKeys(object) {
+		element := object[key]
+		field := plan.output(key)
+		if field == nil {
+			f.unknown = append(f.unknown, pointerPath(pointer, key))
+			result[key] = element
+			continue
+		}
+
+		// Keys that only differ in case would be decoded in the order of the sorted keys instead of the
+		// order of the document, so they are rejected
+		fieldPath := fieldPath(path, field.path)
+		if other, ok := keys[field]; ok {
+			return nil, &Error{Path: fieldPath, Err: fmt.Errorf("%w %q and %q", ErrDuplicateKey, other, key)}
+		}
+		keys[field] = key
+
+		allowed, err := field.allows(f.extractor, f.principal, f.action)
+		if err != nil {
+			return nil, &Error{Path: fieldPath, Err: err}
+		}
+		if !allowed {
+			f.forbidden = append(f.forbidden, pointerPath(pointer, key))
+			continue
+		}
+
+		if field.quoted {
+			result[key] = element
+			continue
+		}
+
+		filtered, err := f.filterValue(element, fieldTypeByIndex(reflectType, field.index), fieldPath,
+			pointerPath(pointer, key))
+		if err != nil {
+			return nil, err
+		}
+		result[key] = filtered
+	}
+
+	return result, nil
+}
+
+// output returns the output field with a name, matching it case insensitively if there is no exact
+// match like encoding/json does, or nil if there is none
+func (p *structPlan) output(name string) *fieldPlan {
+	var folded *fieldPlan
+	for _, field := range p.outputs {
+		if field.name == name {
+			return field
+		}
+		if folded == nil && strings.EqualFold(field.name, name) {
+			folded = field
+		}
+	}
+
+	return folded
+}
+
+// fieldTypeByIndex returns the type of a field of a struct type by its index sequence, going through
+// embedded pointers
+func fieldTypeByIndex(reflectType reflect.Type, index []int) reflect.Type {
+	for i, x := range index {
+		if i > 0 && reflectType.Kind() == reflect.Ptr {
+			reflectType = reflectType.Elem()
+		}
+		reflectType = reflectType.Field(x).Type
+	}
+
+	return reflectType
+}
+
+// sortedKeys returns the keys of a JSON object in order, so that fields are reported deterministically
+func sortedKeys(object map[string]interface{}) []string {
+	keys := make([]string, 0, len(object))
+	for key := range object {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	return keys
+}
+
+// pointerPath returns the JSON pointer of a member of the value at pointer, as defined in RFC 6901
+func pointerPath(pointer string, token string) string {
+	token = strings.ReplaceAll(strings.ReplaceAll(token, "~", "~0"), "/", "~1")
+	return pointer + "/" + token
+}
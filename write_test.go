@@ -0,0 +1,141 @@
+package gopex
+
+import (
+	"encoding/json"
+	"errors"
+	"reflect"
+	"testing"
+)
+
+// Struct written inside other structs
+type ACStruct struct {
+	Street   string `json:"street" pex:"user:rw,admin:rw"`
+	Verified bool   `json:"verified" pex:"user:r,admin:rw"`
+}
+
+// Struct with nested structs to write
+type ADStruct struct {
+	Name      string              `json:"name" pex:"user:rw,admin:rw"`
+	Salary    uint                `json:"salary" pex:"user:r,admin:rw"`
+	Addresses []ACStruct          `json:"addresses" pex:"user:rw,admin:rw"`
+	Contacts  map[string]ACStruct `json:"contacts"`
+	Main      *ACStruct           `json:"main,omitempty"`
+	GStruct
+}
+
+func TestFilterPayload(t *testing.T) {
+	t.Run("TestFilterPayloadDocuments", testFilterPayloadDocuments)
+	t.Run("TestFilterPayloadErrors", testFilterPayloadErrors)
+}
+
+func TestValidateWrite(t *testing.T) {
+	t.Parallel()
+
+	document := []byte(`{"name":"ABC","salary":10,"addresses":[{"street":"DEF"},{"street":"GHI","verified":true}]}`)
+	tables := []struct {
+		principal Principal
+		expected  []Violation
+	}{
+		{NewPrincipal("admin"), nil},
+		{NewPrincipal("user"), []Violation{
+			{Path: "/addresses/1/verified", Roles: []string{"user"}, Permission: "write"},
+			{Path: "/salary", Roles: []string{"user"}, Permission: "write"},
+		}},
+		{NewPrincipal("guest", "sys"), []Violation{
+			{Path: "/addresses", Roles: []string{"guest", "sys"}, Permission: "write"},
+			{Path: "/name", Roles: []string{"guest", "sys"}, Permission: "write"},
+			{Path: "/salary", Roles: []string{"guest", "sys"}, Permission: "write"},
+		}},
+	}
+
+	for _, table := range tables {
+		err := ValidateWriteFor(document, reflect.TypeOf(ADStruct{}), table.principal)
+
+		var forbiddenErr *ForbiddenError
+		if table.expected == nil && err != nil || table.expected != nil && (!errors.As(err, &forbiddenErr) ||
+			!reflect.DeepEqual(forbiddenErr.Violations, table.expected) || !errors.Is(err, ErrForbidden)) {
+			t.Errorf("%s (principal = %+v) was incorrect, got: %v, want: %+v.", t.Name(), table.principal, err,
+				table.expected)
+		}
+	}
+
+	err := ValidateWrite(document, reflect.TypeOf(ADStruct{}), "user")
+	var forbiddenErr *ForbiddenError
+	if !errors.As(err, &forbiddenErr) || forbiddenErr.StatusCode() != 403 ||
+		err.Error() != "gopex: forbidden: /addresses/1/verified, /salary" {
+		t.Fatalf("%s was incorrect, got: %v.", t.Name(), err)
+	}
+
+	body, _ := json.Marshal(err)
+	expected := `{"violations":[{"path":"/addresses/1/verified","roles":["user"],"permission":"write"},` +
+		`{"path":"/salary","roles":["user"],"permission":"write"}]}`
+	if string(body) != expected {
+		t.Errorf("%s was incorrect, got: %s, want: %s.", t.Name(), body, expected)
+	}
+}
+
+func testFilterPayloadDocuments(t *testing.T) {
+	t.Parallel()
+
+	document := `{"name":"ABC","salary":10,"addresses":[{"street":"DEF"},{"street":"GHI","verified":true}],` +
+		`"contacts":{"a/b":{"verified":false}},"main":{"street":"JKL","verified":true},"Name":"MNO","Version":2,` +
+		`"unknown":1}`
+	var decoded map[string]interface{}
+	_ = json.Unmarshal([]byte(document), &decoded)
+
+	tables := []struct {
+		payload   interface{}
+		userType  string
+		expected  string
+		forbidden []string
+	}{
+		{[]byte(document), "user",
+			`{"Version":2,"addresses":[{"street":"DEF"},{"street":"GHI"}],"contacts":{"a/b":{}},` +
+				`"main":{"street":"JKL"},"name":"ABC","unknown":1}`,
+			[]string{"/Name", "/addresses/1/verified", "/contacts/a~1b/verified", "/main/verified", "/salary"}},
+		{json.RawMessage(document), "admin",
+			`{"Name":"MNO","addresses":[{"street":"DEF"},{"street":"GHI","verified":true}],` +
+				`"contacts":{"a/b":{"verified":false}},"main":{"street":"JKL","verified":true},"name":"ABC",` +
+				`"salary":10,"unknown":1}`,
+			[]string{"/Version"}},
+		{decoded, "sys", `{"Name":"MNO","contacts":{"a/b":{}},"main":{},"unknown":1}`,
+			[]string{"/Version", "/addresses", "/contacts/a~1b/verified", "/main/street", "/main/verified", "/name",
+				"/salary"}},
+	}
+
+	for _, table := range tables {
+		filtered, forbidden, err := FilterPayload(table.payload, reflect.TypeOf(&ADStruct{}), table.userType)
+		actual, _ := json.Marshal(filtered)
+		if err != nil || string(actual) != table.expected || !reflect.DeepEqual(forbidden, table.forbidden) {
+			t.Errorf("%s (userType = %s) was incorrect, got: %s, %v, %v, want: %s, %v.", t.Name(), table.userType,
+				actual, forbidden, err, table.expected, table.forbidden)
+		}
+	}
+
+	if _, ok := decoded["salary"]; !ok {
+		t.Errorf("%s was incorrect, the payload was modified: %+v.", t.Name(), decoded)
+	}
+}
+
+func testFilterPayloadErrors(t *testing.T) {
+	t.Parallel()
+
+	tables := []struct {
+		payload     interface{}
+		reflectType reflect.Type
+		expected    error
+	}{
+		{[]byte(`{"Name":`), reflect.TypeOf(ADStruct{}), nil},
+		{map[string]interface{}{"Version": 1}, reflect.TypeOf(JStruct{}), ErrMalformedTag},
+	}
+
+	for _, table := range tables {
+		filtered, forbidden, err := FilterPayload(table.payload, table.reflectType, "user")
+		var pexErr *Error
+		if filtered != nil || forbidden != nil || !errors.As(err, &pexErr) ||
+			table.expected != nil && !errors.Is(err, table.expected) {
+			t.Errorf("%s (payload = %v) was incorrect, got: %v, %v, %v, want: %v.", t.Name(), table.payload,
+				filtered, forbidden, err, table.expected)
+		}
+	}
+}